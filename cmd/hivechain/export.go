@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+// writePayloads writes blocks as a JSON list of engine API payloads. Only PoS blocks
+// can be sent through the engine API, so PoW blocks are rejected.
+func writePayloads(file string, blocks []*types.Block) error {
+	payloads := make([]*beacon.ExecutableDataV1, len(blocks))
+	for i, b := range blocks {
+		if b.Difficulty().Sign() != 0 {
+			return fmt.Errorf("block %d is a PoW block, payloads can only be created for PoS blocks (use -from to skip PoW blocks)", b.NumberU64())
+		}
+		payloads[i] = beacon.BlockToExecutableData(b)
+	}
+	js, err := json.MarshalIndent(payloads, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, js, 0644)
+}
+
+// Era1 archives store blocks with their receipts and total difficulty. A file holds up to
+// one epoch of 8192 blocks, and is a sequence of e2store entries:
+//
+//	Version | (CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty)* | Accumulator | BlockIndex
+const (
+	eraEpochSize = 8192
+
+	e2Version            = 0x3265
+	e2CompressedHeader   = 0x03
+	e2CompressedBody     = 0x04
+	e2CompressedReceipts = 0x05
+	e2TotalDifficulty    = 0x06
+	e2Accumulator        = 0x07
+	e2BlockIndex         = 0x3266
+)
+
+// writeEra writes the blocks as Era1 files to dir, one file per epoch. The blockchain
+// must contain the blocks, it provides their receipts and total difficulty.
+func writeEra(dir, network string, blockchain *core.BlockChain, blocks []*types.Block) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for len(blocks) > 0 {
+		epoch := blocks[0].NumberU64() / eraEpochSize
+		n := 1
+		for n < len(blocks) && blocks[n].NumberU64()/eraEpochSize == epoch {
+			n++
+		}
+		if err := writeEraFile(dir, network, epoch, blockchain, blocks[:n]); err != nil {
+			return err
+		}
+		blocks = blocks[n:]
+	}
+	return nil
+}
+
+func writeEraFile(dir, network string, epoch uint64, blockchain *core.BlockChain, blocks []*types.Block) error {
+	var (
+		buf     = new(bytes.Buffer)
+		w       = &e2storeWriter{w: buf}
+		hashes  []common.Hash
+		tds     []*big.Int
+		offsets []int64
+	)
+	if err := w.write(e2Version, nil); err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		td := blockchain.GetTd(b.Hash(), b.NumberU64())
+		if td == nil {
+			return fmt.Errorf("total difficulty of block %d not found", b.NumberU64())
+		}
+		receipts := blockchain.GetReceiptsByHash(b.Hash())
+		if receipts == nil {
+			receipts = types.Receipts{}
+		}
+		offsets = append(offsets, w.offset)
+		if err := w.writeCompressed(e2CompressedHeader, b.Header()); err != nil {
+			return err
+		}
+		if err := w.writeCompressed(e2CompressedBody, b.Body()); err != nil {
+			return err
+		}
+		if err := w.writeCompressed(e2CompressedReceipts, receipts); err != nil {
+			return err
+		}
+		if err := w.write(e2TotalDifficulty, uint256LE(td)); err != nil {
+			return err
+		}
+		hashes = append(hashes, b.Hash())
+		tds = append(tds, td)
+	}
+	root := eraAccumulator(hashes, tds)
+	if err := w.write(e2Accumulator, root[:]); err != nil {
+		return err
+	}
+
+	// The index contains the offsets of the blocks relative to the index entry.
+	index := make([]byte, 16+8*len(offsets))
+	binary.LittleEndian.PutUint64(index, blocks[0].NumberU64())
+	for i, offset := range offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], uint64(offset-w.offset))
+	}
+	binary.LittleEndian.PutUint64(index[8+8*len(offsets):], uint64(len(offsets)))
+	if err := w.write(e2BlockIndex, index); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%05d-%s.era1", network, epoch, hex.EncodeToString(root[:4]))
+	fmt.Printf("writing %s (blocks %d-%d)\n", name, blocks[0].NumberU64(), blocks[len(blocks)-1].NumberU64())
+	return ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644)
+}
+
+// e2storeWriter writes e2store entries: a 2-byte type, 4-byte length and two reserved
+// bytes, followed by the data. All integers are little-endian.
+type e2storeWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (w *e2storeWriter) write(typ uint16, data []byte) error {
+	var header [8]byte
+	binary.LittleEndian.PutUint16(header[0:], typ)
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(data)))
+	if _, err := w.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	w.offset += int64(len(header) + len(data))
+	return nil
+}
+
+// writeCompressed writes the RLP encoding of v, compressed with snappy framing.
+func (w *e2storeWriter) writeCompressed(typ uint16, v interface{}) error {
+	enc, err := rlp.EncodeToBytes(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	sw := snappy.NewBufferedWriter(&buf)
+	if _, err := sw.Write(enc); err != nil {
+		return err
+	}
+	if err := sw.Close(); err != nil {
+		return err
+	}
+	return w.write(typ, buf.Bytes())
+}
+
+// eraAccumulator computes the SSZ hash tree root of the header records
+// List[(block_hash, total_difficulty), 8192].
+func eraAccumulator(hashes []common.Hash, tds []*big.Int) common.Hash {
+	layer := make([]common.Hash, len(hashes))
+	for i := range hashes {
+		layer[i] = sha256.Sum256(append(hashes[i].Bytes(), uint256LE(tds[i])...))
+	}
+	var zero common.Hash // root of an empty subtree at the current depth
+	for size := eraEpochSize; size > 1; size /= 2 {
+		next := make([]common.Hash, (len(layer)+1)/2)
+		for i := range next {
+			left, right := layer[2*i], zero
+			if 2*i+1 < len(layer) {
+				right = layer[2*i+1]
+			}
+			next[i] = sha256.Sum256(append(left.Bytes(), right.Bytes()...))
+		}
+		layer = next
+		zero = sha256.Sum256(append(zero.Bytes(), zero.Bytes()...))
+	}
+	root := zero
+	if len(layer) > 0 {
+		root = layer[0]
+	}
+	// Mix in the list length.
+	return sha256.Sum256(append(root.Bytes(), uint256LE(big.NewInt(int64(len(hashes))))...))
+}
+
+// uint256LE encodes n as a 32-byte little-endian integer.
+func uint256LE(n *big.Int) []byte {
+	b := n.FillBytes(make([]byte, 32))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+func TestEraAccumulator(t *testing.T) {
+	// The expected roots were computed by merkleizing all 8192 leaves of
+	// List[HeaderRecord, 8192] without any shortcuts.
+	tests := []struct {
+		n    int
+		root string
+	}{
+		{0, "4a8c3a07c8d23adc5bac61157555c3c784d53d9bc110c1370809bd23cd93777d"},
+		{1, "5030b05f738f6dcc7602d31f9e7e7a07dc788bd4907e85d4ca4f75769a46de74"},
+		{3, "f3e27ab1af81a5438080160916167c3e9e03003d45bca9b4113de6fbe3b54705"},
+		{eraEpochSize, "2e850782ca34484561a0d408de44ba36ae39e3e093203a25682000f9d6c89da2"},
+	}
+	for _, test := range tests {
+		var (
+			hashes []common.Hash
+			tds    []*big.Int
+		)
+		for i := 1; i <= test.n; i++ {
+			hashes = append(hashes, common.BigToHash(big.NewInt(int64(i))))
+			tds = append(tds, big.NewInt(int64(i)))
+		}
+		if root := eraAccumulator(hashes, tds); root != common.HexToHash(test.root) {
+			t.Errorf("%d records: wrong root %x, want %s", test.n, root, test.root)
+		}
+	}
+}
+
+// e2Entry is an entry of an e2store file.
+type e2Entry struct {
+	typ    uint16
+	offset int64
+	data   []byte
+}
+
+func readE2Store(t *testing.T, data []byte) []e2Entry {
+	var entries []e2Entry
+	for offset := int64(0); offset < int64(len(data)); {
+		typ := binary.LittleEndian.Uint16(data[offset:])
+		length := int64(binary.LittleEndian.Uint32(data[offset+2:]))
+		if offset+8+length > int64(len(data)) {
+			t.Fatalf("entry at offset %d exceeds file size", offset)
+		}
+		entries = append(entries, e2Entry{typ, offset, data[offset+8 : offset+8+length]})
+		offset += 8 + length
+	}
+	return entries
+}
+
+func TestWriteEraFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := generatorConfig{
+		blockCount:   4,
+		blockTimeSec: 30,
+		powMode:      ethash.ModeFullFake,
+		genesis:      testGenesis(),
+	}
+	if err := cfg.writeTestChain(dir); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := readChain(filepath.Join(dir, "chain.rlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	importCfg := generatorConfig{powMode: ethash.ModeFullFake, genesis: testGenesis()}
+	blockchain, err := importCfg.importChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	out := t.TempDir()
+	blocks := append([]*types.Block{blockchain.Genesis()}, chain...)
+	if err := writeEra(out, "test", blockchain, blocks); err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("wrong number of era files: %d", len(files))
+	}
+	data, err := os.ReadFile(filepath.Join(out, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := readE2Store(t, data)
+
+	// Version, four entries per block, accumulator and index.
+	if len(entries) != 3+4*len(blocks) {
+		t.Fatalf("wrong number of entries: %d", len(entries))
+	}
+	if entries[0].typ != e2Version {
+		t.Fatalf("first entry has type %#x, want version", entries[0].typ)
+	}
+	var (
+		hashes []common.Hash
+		tds    []*big.Int
+	)
+	for _, b := range blocks {
+		hashes = append(hashes, b.Hash())
+		tds = append(tds, blockchain.GetTd(b.Hash(), b.NumberU64()))
+	}
+	root := eraAccumulator(hashes, tds)
+	acc := entries[len(entries)-2]
+	if acc.typ != e2Accumulator || !bytes.Equal(acc.data, root[:]) {
+		t.Fatalf("wrong accumulator entry %#x %x, want %x", acc.typ, acc.data, root)
+	}
+	if want := "test-00000-" + hex.EncodeToString(root[:4]) + ".era1"; files[0].Name() != want {
+		t.Errorf("wrong file name %s, want %s", files[0].Name(), want)
+	}
+
+	// The index must point at the header of each block.
+	index := entries[len(entries)-1]
+	if index.typ != e2BlockIndex {
+		t.Fatalf("last entry has type %#x, want block index", index.typ)
+	}
+	if start := binary.LittleEndian.Uint64(index.data); start != 0 {
+		t.Errorf("wrong index start %d", start)
+	}
+	if count := binary.LittleEndian.Uint64(index.data[len(index.data)-8:]); count != uint64(len(blocks)) {
+		t.Errorf("wrong index count %d", count)
+	}
+	byOffset := make(map[int64]e2Entry)
+	for _, e := range entries {
+		byOffset[e.offset] = e
+	}
+	for i, b := range blocks {
+		rel := int64(binary.LittleEndian.Uint64(index.data[8+8*i:]))
+		e, ok := byOffset[index.offset+rel]
+		if !ok || e.typ != e2CompressedHeader {
+			t.Fatalf("block %d: index doesn't point at a header entry", i)
+		}
+		enc, err := io.ReadAll(snappy.NewReader(bytes.NewReader(e.data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var header types.Header
+		if err := rlp.DecodeBytes(enc, &header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Hash() != b.Hash() {
+			t.Errorf("block %d: wrong header hash %x", i, header.Hash())
+		}
+	}
+}
+
+func TestWritePayloadsRejectsPoW(t *testing.T) {
+	dir := t.TempDir()
+	pow := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)})
+	err := writePayloads(filepath.Join(dir, "payloads.json"), []*types.Block{pow})
+	if err == nil || !strings.Contains(err.Error(), "PoW") {
+		t.Fatalf("wrong error for PoW block: %v", err)
+	}
+	pos := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), Difficulty: new(big.Int), BaseFee: big.NewInt(7)})
+	if err := writePayloads(filepath.Join(dir, "payloads.json"), []*types.Block{pos}); err != nil {
+		t.Fatalf("PoS block rejected: %v", err)
+	}
+}
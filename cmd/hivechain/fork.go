@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// forkManifest describes the branches written by 'hivechain fork'.
+type forkManifest struct {
+	CommonAncestor blockRef              `json:"commonAncestor"`
+	Canonical      string                `json:"canonical"` // branch with the highest total difficulty, see canonicalBranch
+	Branches       map[string]forkBranch `json:"branches"`
+}
+
+type forkBranch struct {
+	File            string       `json:"file"`
+	Head            blockRef     `json:"head"`
+	TotalDifficulty *hexutil.Big `json:"totalDifficulty"`
+}
+
+type blockRef struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+func newBlockRef(b *types.Block) blockRef {
+	return blockRef{Number: b.NumberU64(), Hash: b.Hash()}
+}
+
+// readChain reads all blocks of a chain.rlp file.
+func readChain(file string) ([]*types.Block, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var blocks []*types.Block
+	s := rlp.NewStream(bufio.NewReader(fd), 0)
+	for i := 0; ; i++ {
+		var block types.Block
+		err := s.Decode(&block)
+		if err == io.EOF {
+			return blocks, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: block %d: %v", file, i, err)
+		}
+		blocks = append(blocks, &block)
+	}
+}
+
+// writeFork creates a side chain of cfg.blockCount blocks, branching off the given
+// chain at block forkAt. Both branches and the manifest are written to outputPath.
+func (cfg generatorConfig) writeFork(outputPath string, chain []*types.Block, forkAt uint64) error {
+	if forkAt > uint64(len(chain)) {
+		return fmt.Errorf("fork point %d is beyond the chain head %d", forkAt, len(chain))
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := cfg.genesis.MustCommit(db)
+	engine := cfg.engine()
+	setTerminalDifficulty(cfg.genesis.Config, genesis, chain)
+
+	// Import the chain. The state of every block is written to the database, so the
+	// side chain can be generated on top of any block.
+	blockchain, err := core.NewBlockChain(db, archiveCacheConfig(), &cfg.genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("can't create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		return fmt.Errorf("chain validation error: %v", err)
+	}
+
+	// Create the side chain and import it. This runs all block validation rules.
+	ancestor := genesis
+	if forkAt > 0 {
+		ancestor = chain[forkAt-1]
+	}
+	if cfg.blockTimeSec == 0 {
+		cfg.blockTimeSec = defaultForkBlockTime
+		if forkAt < uint64(len(chain)) {
+			cfg.blockTimeSec = forkBlockTime(cfg.genesis.Config, ancestor, chain[forkAt])
+		}
+	}
+	ancestorTD := totalDifficulty(genesis, chain[:forkAt])
+	side := cfg.generateBlocks(db, engine, ancestor, ancestorTD, cfg.blockCount, cfg.blockModifier())
+	if _, err := blockchain.InsertChain(side); err != nil {
+		return fmt.Errorf("fork validation error: %v", err)
+	}
+	fork := append(append([]*types.Block{}, chain[:forkAt]...), side...)
+
+	// Write out both branches.
+	manifest := forkManifest{
+		CommonAncestor: newBlockRef(ancestor),
+		Branches:       make(map[string]forkBranch),
+	}
+	branches := []struct {
+		name, file string
+		blocks     []*types.Block
+	}{
+		{"main", "chain.rlp", chain},
+		{"fork", "fork.rlp", fork},
+	}
+	tds := make(map[string]*big.Int)
+	for _, b := range branches {
+		if err := writeBlocks(filepath.Join(outputPath, b.file), b.blocks); err != nil {
+			return err
+		}
+		head := genesis
+		if len(b.blocks) > 0 {
+			head = b.blocks[len(b.blocks)-1]
+		}
+		td := totalDifficulty(genesis, b.blocks)
+		manifest.Branches[b.name] = forkBranch{
+			File:            b.file,
+			Head:            newBlockRef(head),
+			TotalDifficulty: (*hexutil.Big)(td),
+		}
+		tds[b.name] = td
+	}
+	manifest.Canonical = canonicalBranch(tds, cfg.genesis.Config.TerminalTotalDifficulty)
+	js, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(outputPath, "fork.json"), js, 0644)
+}
+
+// canonicalBranch returns the name of the branch with the highest total difficulty.
+// It returns the empty string when there is no such branch: when the branches have the
+// same total difficulty, or when all of them reached the terminal total difficulty. For
+// PoS chains, the canonical branch is chosen by the consensus layer and not by the
+// total difficulty.
+func canonicalBranch(tds map[string]*big.Int, ttd *big.Int) string {
+	var (
+		canonical string
+		maxTD     *big.Int
+		tie       bool
+		allPoS    = ttd != nil
+	)
+	for name, td := range tds {
+		if ttd == nil || td.Cmp(ttd) < 0 {
+			allPoS = false
+		}
+		switch {
+		case maxTD == nil || td.Cmp(maxTD) > 0:
+			canonical, maxTD, tie = name, td, false
+		case td.Cmp(maxTD) == 0:
+			tie = true
+		}
+	}
+	if tie || allPoS {
+		return ""
+	}
+	return canonical
+}
+
+// defaultForkBlockTime is the block time of side chains forking at the chain head.
+const defaultForkBlockTime = 30
+
+// forkBlockTime returns the smallest block time for which the first side chain block
+// differs from the main chain block at the same height in timestamp and, for PoW
+// blocks, difficulty.
+func forkBlockTime(config *params.ChainConfig, ancestor, main *types.Block) int {
+	for bt := 1; bt <= 600; bt++ {
+		time := ancestor.Time() + uint64(bt)
+		if time == main.Time() {
+			continue
+		}
+		if main.Difficulty().Sign() > 0 && ethash.CalcDifficulty(config, time, ancestor.Header()).Cmp(main.Difficulty()) == 0 {
+			continue
+		}
+		return bt
+	}
+	return defaultForkBlockTime
+}
+
+// totalDifficulty returns the total difficulty of a chain, including the genesis block.
+func totalDifficulty(genesis *types.Block, blocks []*types.Block) *big.Int {
+	td := new(big.Int).Set(genesis.Difficulty())
+	for _, b := range blocks {
+		td.Add(td, b.Difficulty())
+	}
+	return td
+}
+
+// writeBlocks writes blocks to an RLP file.
+func writeBlocks(file string, blocks []*types.Block) error {
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	for _, b := range blocks {
+		if err := b.EncodeRLP(w); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
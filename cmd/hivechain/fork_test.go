@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func testGenesis() core.Genesis {
+	config := *params.AllEthashProtocolChanges
+	return core.Genesis{
+		Config:     &config,
+		Difficulty: big.NewInt(0x20000),
+		GasLimit:   8000000,
+	}
+}
+
+func TestForkPoS(t *testing.T) {
+	dir := t.TempDir()
+	cfg := generatorConfig{
+		blockCount:    5,
+		posBlockCount: 3,
+		blockTimeSec:  30,
+		powMode:       ethash.ModeFullFake,
+		genesis:       testGenesis(),
+		isPoS:         true,
+	}
+	if err := cfg.writeTestChain(dir); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := readChain(filepath.Join(dir, "chain.rlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fork before and after the transition. The TTD isn't in the genesis and must
+	// be inferred from the chain.
+	for _, forkAt := range []uint64{3, 6} {
+		out := t.TempDir()
+		forkCfg := generatorConfig{
+			blockCount: 4,
+			powMode:    ethash.ModeFullFake,
+			genesis:    testGenesis(),
+		}
+		if err := forkCfg.writeFork(out, chain, forkAt); err != nil {
+			t.Fatalf("fork at %d: %v", forkAt, err)
+		}
+		fork, err := readChain(filepath.Join(out, "fork.rlp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fork) != int(forkAt)+4 {
+			t.Fatalf("fork at %d: wrong fork length %d", forkAt, len(fork))
+		}
+		main, side := chain[forkAt], fork[forkAt]
+		if main.Time() == side.Time() {
+			t.Errorf("fork at %d: first fork block has same timestamp as main chain", forkAt)
+		}
+		if main.Difficulty().Sign() > 0 && main.Difficulty().Cmp(side.Difficulty()) == 0 {
+			t.Errorf("fork at %d: first fork block has same difficulty as main chain", forkAt)
+		}
+		if head := fork[len(fork)-1]; head.Difficulty().Sign() != 0 {
+			t.Errorf("fork at %d: fork head is not a PoS block", forkAt)
+		}
+
+		var manifest forkManifest
+		data, err := os.ReadFile(filepath.Join(out, "fork.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if manifest.CommonAncestor.Hash != fork[forkAt-1].Hash() {
+			t.Errorf("fork at %d: wrong common ancestor in manifest", forkAt)
+		}
+		// Both branches reach the TTD, so total difficulty doesn't choose between them.
+		if manifest.Canonical != "" {
+			t.Errorf("fork at %d: manifest has canonical branch %q for PoS chain", forkAt, manifest.Canonical)
+		}
+	}
+}
+
+func TestCanonicalBranch(t *testing.T) {
+	tests := []struct {
+		main, fork int64
+		ttd        *big.Int
+		want       string
+	}{
+		{10, 12, nil, "fork"},
+		{12, 10, nil, "main"},
+		{10, 10, nil, ""},
+		{10, 12, big.NewInt(20), "fork"},
+		{10, 22, big.NewInt(20), "fork"},
+		{20, 20, big.NewInt(20), ""},
+		{21, 22, big.NewInt(20), ""},
+	}
+	for _, test := range tests {
+		tds := map[string]*big.Int{"main": big.NewInt(test.main), "fork": big.NewInt(test.fork)}
+		if got := canonicalBranch(tds, test.ttd); got != test.want {
+			t.Errorf("main %d, fork %d, ttd %v: got %q, want %q", test.main, test.fork, test.ttd, got, test.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/ecdsa"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -23,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -56,27 +58,61 @@ type generatorConfig struct {
 	genesis       core.Genesis
 	isPoS         bool                            // true if the generator should create post pos blocks
 	modifyBlock   func(*types.Block) *types.Block // modify the block during exporting
+	workload      *workload                       // replaces the default transactions if set
+	extra         []byte                          // header extra data of generated blocks
+	stateDumps    bool                            // write the state of every block
 }
 
 // loadGenesis loads genesis.json.
 func loadGenesis(file string) (*core.Genesis, error) {
 	var gspec core.Genesis
-	err := common.LoadJSON(file, &gspec)
-	return &gspec, err
+	if err := common.LoadJSON(file, &gspec); err != nil {
+		return nil, err
+	}
+	// The go-ethereum version used by hivechain ignores timestamp-based forks. Reject
+	// them instead of generating a chain which doesn't activate them.
+	var forks struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := common.LoadJSON(file, &forks); err != nil {
+		return nil, err
+	}
+	for _, name := range unsupportedForks {
+		if _, ok := forks.Config[name]; ok {
+			return nil, fmt.Errorf("%s: fork %q is not supported by hivechain", file, name)
+		}
+	}
+	return &gspec, nil
 }
 
+// unsupportedForks are the timestamp-activated forks of the genesis config, which
+// hivechain can't generate yet.
+var unsupportedForks = []string{"shanghaiTime", "cancunTime", "pragueTime"}
+
 // writeTestChain creates a test chain with no transactions or other
 // modifications based on an externally specified genesis file. The blockTimeInSeconds is
 // used to manipulate the block difficulty.
 func (cfg generatorConfig) writeTestChain(outputPath string) error {
-	blockModifier := func(i int, gen *core.BlockGen) {
+	// Do not modify blocks
+	cfg.modifyBlock = func(b *types.Block) *types.Block { return b }
+	return cfg.generateAndSave(outputPath, cfg.blockModifier())
+}
+
+// blockModifier returns the function which sets the block time and adds transactions
+// to generated blocks.
+func (cfg generatorConfig) blockModifier() func(i int, gen *core.BlockGen) {
+	return func(i int, gen *core.BlockGen) {
 		log.Println("generating block", gen.Number())
 		gen.OffsetTime(int64((i+1)*int(cfg.blockTimeSec) - 10))
-		cfg.addTxForKnownAccounts(i, gen)
+		if cfg.extra != nil {
+			gen.SetExtra(cfg.extra)
+		}
+		if cfg.workload != nil {
+			cfg.workload.addTxs(gen, cfg.blockGasLimit(gen))
+		} else {
+			cfg.addTxForKnownAccounts(i, gen)
+		}
 	}
-	// Do not modify blocks
-	cfg.modifyBlock = func(b *types.Block) *types.Block { return b }
-	return cfg.generateAndSave(outputPath, blockModifier)
 }
 
 const (
@@ -96,13 +132,8 @@ func (cfg generatorConfig) addTxForKnownAccounts(i int, gen *core.BlockGen) {
 
 	txType := (i / cfg.txInterval) % txTypeMax
 
-	var gasLimit uint64
-	if gen.Number().Uint64() > 0 {
-		prev := gen.PrevBlock(-1)
-		gasLimit = core.CalcGasLimit(prev.GasLimit(), cfg.genesis.GasLimit)
-	}
-
 	var (
+		gasLimit = cfg.blockGasLimit(gen)
 		txGasSum uint64
 		txCount  = 0
 		accounts = make(map[common.Address]*ecdsa.PrivateKey)
@@ -135,6 +166,15 @@ func (cfg generatorConfig) addTxForKnownAccounts(i int, gen *core.BlockGen) {
 	}
 }
 
+// blockGasLimit returns the gas limit of the block being generated.
+func (cfg generatorConfig) blockGasLimit(gen *core.BlockGen) uint64 {
+	if gen.Number().Uint64() == 0 {
+		return 0
+	}
+	prev := gen.PrevBlock(-1)
+	return core.CalcGasLimit(prev.GasLimit(), cfg.genesis.GasLimit)
+}
+
 // generateTx creates a random transaction signed by the given account.
 func generateTx(txType int, key *ecdsa.PrivateKey, genesis *core.Genesis, gen *core.BlockGen) *types.Transaction {
 	var (
@@ -189,16 +229,7 @@ func (cfg generatorConfig) generateAndSave(path string, blockModifier func(i int
 	db := rawdb.NewMemoryDatabase()
 	genesis := cfg.genesis.MustCommit(db)
 	config := cfg.genesis.Config
-	ethashConf := ethash.Config{
-		PowMode:        cfg.powMode,
-		CachesInMem:    2,
-		DatasetsOnDisk: 2,
-		DatasetDir:     ethashDir(),
-	}
-
-	powEngine := ethash.New(ethashConf, nil, false)
-	posEngine := beacon.New(powEngine)
-	engine := instaSeal{posEngine}
+	engine := cfg.engine()
 
 	// Create the PoW chain.
 	chain, _ := core.GenerateChain(config, genesis, engine, db, cfg.blockCount, blockModifier)
@@ -206,18 +237,13 @@ func (cfg generatorConfig) generateAndSave(path string, blockModifier func(i int
 	// Create the PoS chain extension.
 	if cfg.isPoS {
 		// Set TTD to the head of the PoW chain.
-		totalDifficulty := big.NewInt(0)
-		for _, b := range chain {
-			totalDifficulty.Add(totalDifficulty, b.Difficulty())
-		}
-		config.TerminalTotalDifficulty = totalDifficulty
-
-		posChain, _ := core.GenerateChain(config, chain[len(chain)-1], engine, db, cfg.posBlockCount, blockModifier)
+		config.TerminalTotalDifficulty = totalDifficulty(genesis, chain)
+		posChain := cfg.generateBlocks(db, engine, chain[len(chain)-1], config.TerminalTotalDifficulty, cfg.posBlockCount, blockModifier)
 		chain = append(chain, posChain...)
 	}
 
 	// Import the chain. This runs all block validation rules.
-	blockchain, err := core.NewBlockChain(db, nil, &cfg.genesis, nil, engine, vm.Config{}, nil, nil)
+	blockchain, err := core.NewBlockChain(db, archiveCacheConfig(), &cfg.genesis, nil, engine, vm.Config{}, nil, nil)
 	if err != nil {
 		return fmt.Errorf("can't create blockchain: %v", err)
 	}
@@ -242,7 +268,60 @@ func (cfg generatorConfig) generateAndSave(path string, blockModifier func(i int
 	if err := ioutil.WriteFile(filepath.Join(path, "chain_poststate.json"), dump, 0644); err != nil {
 		return err
 	}
-	return nil
+	return writeChainIndex(blockchain, path, cfg.stateDumps)
+}
+
+// generateBlocks creates n blocks on top of parent, whose total difficulty is parentTD.
+// Blocks whose parent has reached the terminal total difficulty are PoS blocks.
+func (cfg generatorConfig) generateBlocks(db ethdb.Database, engine consensus.Engine, parent *types.Block, parentTD *big.Int, n int, blockModifier func(i int, gen *core.BlockGen)) []*types.Block {
+	var (
+		config = cfg.genesis.Config
+		td     = new(big.Int).Set(parentTD)
+		blocks = make([]*types.Block, 0, n)
+	)
+	// Blocks are generated one at a time because the chain maker has no access to the
+	// total difficulty, so it can't tell when TTD is reached.
+	for i := 0; i < n; i++ {
+		index := i
+		modifier := func(_ int, gen *core.BlockGen) { blockModifier(index, gen) }
+		if ttd := config.TerminalTotalDifficulty; ttd != nil && td.Cmp(ttd) >= 0 {
+			modifier = posModifier(modifier)
+		}
+		generated, _ := core.GenerateChain(config, parent, engine, db, 1, modifier)
+		parent = generated[0]
+		td.Add(td, parent.Difficulty())
+		blocks = append(blocks, parent)
+	}
+	return blocks
+}
+
+// posModifier makes a block modifier create PoS blocks. The blocks are marked as PoS
+// after the block modifier runs, since changing the block time recomputes the
+// difficulty.
+func posModifier(blockModifier func(i int, gen *core.BlockGen)) func(i int, gen *core.BlockGen) {
+	return func(i int, gen *core.BlockGen) {
+		blockModifier(i, gen)
+		gen.SetDifficulty(common.Big0)
+	}
+}
+
+// archiveCacheConfig configures a blockchain to keep the state of every block, and the
+// preimages needed to include account addresses in state dumps.
+func archiveCacheConfig() *core.CacheConfig {
+	return &core.CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true, Preimages: true}
+}
+
+// engine creates the consensus engine used for generating and validating blocks.
+func (cfg generatorConfig) engine() consensus.Engine {
+	ethashConf := ethash.Config{
+		PowMode:        cfg.powMode,
+		CachesInMem:    2,
+		DatasetsOnDisk: 2,
+		DatasetDir:     ethashDir(),
+	}
+	powEngine := ethash.New(ethashConf, nil, false)
+	posEngine := beacon.New(powEngine)
+	return instaSeal{posEngine}
 }
 
 // ethashDir returns the directory for storing ethash datasets.
@@ -275,6 +354,9 @@ func (e instaSeal) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header
 	if err != nil {
 		return nil, err
 	}
+	if block.Difficulty().Sign() == 0 {
+		return block, nil // PoS blocks are not sealed.
+	}
 	sealedBlock := make(chan *types.Block, 1)
 	if err = e.Engine.Seal(nil, block, sealedBlock, nil); err != nil {
 		return nil, err
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadGenesisUnsupportedForks(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	ok := write("ok.json", `{"config": {"chainId": 1, "londonBlock": 0}, "difficulty": "0x20000", "gasLimit": "0x7a1200", "alloc": {}}`)
+	if _, err := loadGenesis(ok); err != nil {
+		t.Fatalf("valid genesis rejected: %v", err)
+	}
+	shanghai := write("shanghai.json", `{"config": {"chainId": 1, "londonBlock": 0, "shanghaiTime": 0}, "difficulty": "0x20000", "gasLimit": "0x7a1200", "alloc": {}}`)
+	if _, err := loadGenesis(shanghai); err == nil || !strings.Contains(err.Error(), "shanghaiTime") {
+		t.Fatalf("wrong error for shanghaiTime genesis: %v", err)
+	}
+}
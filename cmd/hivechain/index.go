@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// chainIndex lists objects of a generated chain, for use in test assertions.
+// It is written to chain_index.json.
+type chainIndex struct {
+	Genesis   common.Hash               `json:"genesis"`
+	Head      blockRef                  `json:"head"`
+	Blocks    []indexBlock              `json:"blocks"`
+	Txs       map[string][]common.Hash  `json:"txs"` // tx hashes by type
+	Logs      []indexLog                `json:"logs"`
+	Contracts []indexContract           `json:"contracts"`
+	Storage   map[common.Address]uint64 `json:"accountsWithStorage"` // number of slots at head
+
+	TerminalTotalDifficulty *hexutil.Big `json:"terminalTotalDifficulty,omitempty"`
+}
+
+type indexBlock struct {
+	Number    uint64        `json:"number"`
+	Hash      common.Hash   `json:"hash"`
+	StateRoot common.Hash   `json:"stateRoot"`
+	Txs       []common.Hash `json:"txs"`
+}
+
+type indexLog struct {
+	Block   uint64         `json:"block"`
+	Tx      common.Hash    `json:"tx"`
+	Index   uint           `json:"index"`
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+type indexContract struct {
+	Address  common.Address `json:"address"`
+	Block    uint64         `json:"block"`
+	Tx       common.Hash    `json:"tx"`
+	Deployer common.Address `json:"deployer"`
+}
+
+var txTypeNames = map[uint8]string{
+	types.LegacyTxType:     "legacy",
+	types.AccessListTxType: "access-list",
+	types.DynamicFeeTxType: "dynamic-fee",
+}
+
+func txTypeName(t uint8) string {
+	if name, ok := txTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type-%d", t)
+}
+
+// writeChainIndex writes chain_index.json. If stateDumps is set, the state of every block
+// is also written to the 'state' directory.
+func writeChainIndex(blockchain *core.BlockChain, path string, stateDumps bool) error {
+	var (
+		head   = blockchain.CurrentBlock()
+		config = blockchain.Config()
+		index  = chainIndex{
+			Genesis:   blockchain.Genesis().Hash(),
+			Head:      newBlockRef(head),
+			Blocks:    []indexBlock{},
+			Txs:       make(map[string][]common.Hash),
+			Logs:      []indexLog{},
+			Contracts: []indexContract{},
+			Storage:   make(map[common.Address]uint64),
+
+			TerminalTotalDifficulty: (*hexutil.Big)(config.TerminalTotalDifficulty),
+		}
+	)
+	if stateDumps {
+		if err := os.MkdirAll(filepath.Join(path, "state"), 0755); err != nil {
+			return err
+		}
+	}
+	for n := uint64(1); n <= head.NumberU64(); n++ {
+		block := blockchain.GetBlockByNumber(n)
+		if block == nil {
+			return fmt.Errorf("block %d not found", n)
+		}
+		b := indexBlock{Number: n, Hash: block.Hash(), StateRoot: block.Root(), Txs: []common.Hash{}}
+		signer := types.MakeSigner(config, block.Number())
+		receipts := blockchain.GetReceiptsByHash(block.Hash())
+		for i, tx := range block.Transactions() {
+			b.Txs = append(b.Txs, tx.Hash())
+			name := txTypeName(tx.Type())
+			index.Txs[name] = append(index.Txs[name], tx.Hash())
+			if i >= len(receipts) {
+				continue
+			}
+			r := receipts[i]
+			for _, l := range r.Logs {
+				index.Logs = append(index.Logs, indexLog{
+					Block:   n,
+					Tx:      tx.Hash(),
+					Index:   l.Index,
+					Address: l.Address,
+					Topics:  l.Topics,
+					Data:    l.Data,
+				})
+			}
+			if tx.To() == nil && r.Status == types.ReceiptStatusSuccessful {
+				sender, _ := types.Sender(signer, tx)
+				index.Contracts = append(index.Contracts, indexContract{
+					Address:  r.ContractAddress,
+					Block:    n,
+					Tx:       tx.Hash(),
+					Deployer: sender,
+				})
+			}
+		}
+		index.Blocks = append(index.Blocks, b)
+
+		if stateDumps {
+			statedb, err := blockchain.StateAt(block.Root())
+			if err != nil {
+				return fmt.Errorf("state of block %d: %v", n, err)
+			}
+			file := filepath.Join(path, "state", fmt.Sprintf("%d.json", n))
+			if err := ioutil.WriteFile(file, statedb.Dump(&state.DumpConfig{}), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	headstate, err := blockchain.State()
+	if err != nil {
+		return err
+	}
+	for addr, account := range headstate.RawDump(&state.DumpConfig{}).Accounts {
+		if len(account.Storage) > 0 {
+			index.Storage[addr] = uint64(len(account.Storage))
+		}
+	}
+
+	js, err := json.MarshalIndent(&index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(path, "chain_index.json"), js, 0644)
+}
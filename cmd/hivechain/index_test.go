@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// indexWorkload deploys a contract whose code stores 1 in slot 0 and emits a log with
+// topic 0x2a, then calls it. A transfer is added to the same block as the call.
+const indexWorkload = `
+contracts:
+  - name: logger
+    code: "0x600d600c600039600d6000f36001600055602a60006000a100"
+txs:
+  - name: deploy
+    kind: deploy
+    contract: logger
+  - name: call
+    kind: call
+    type: legacy
+    contract: logger
+  - name: send
+    kind: transfer
+    type: access-list
+    to: "0x0000000000000000000000000000000000000100"
+    value: 1 gwei
+blocks:
+  - from: 1
+    to: 1
+    mix: [{tx: deploy, sender: 0}]
+  - from: 2
+    to: 2
+    mix: [{tx: call}, {tx: send}]
+`
+
+func TestChainIndex(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "workload.yaml")
+	if err := os.WriteFile(file, []byte(indexWorkload), 0644); err != nil {
+		t.Fatal(err)
+	}
+	genesis := testGenesis()
+	genesis.Alloc = make(core.GenesisAlloc)
+	for addr := range knownAccounts {
+		genesis.Alloc[addr] = core.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	w, err := loadWorkload(file, &genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	cfg := generatorConfig{
+		blockCount:   3,
+		blockTimeSec: 30,
+		powMode:      ethash.ModeFullFake,
+		genesis:      genesis,
+		workload:     w,
+		stateDumps:   true,
+	}
+	if err := cfg.writeTestChain(out); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := readChain(filepath.Join(out, "chain.rlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var index chainIndex
+	data, err := os.ReadFile(filepath.Join(out, "chain_index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks.
+	if index.Head.Hash != chain[len(chain)-1].Hash() || index.Head.Number != 3 {
+		t.Errorf("wrong head %+v", index.Head)
+	}
+	if len(index.Blocks) != len(chain) {
+		t.Fatalf("index has %d blocks, want %d", len(index.Blocks), len(chain))
+	}
+	for i, b := range index.Blocks {
+		block := chain[i]
+		if b.Number != block.NumberU64() || b.Hash != block.Hash() || b.StateRoot != block.Root() {
+			t.Errorf("block %d: wrong index entry %+v", block.NumberU64(), b)
+		}
+		if len(b.Txs) != len(block.Transactions()) {
+			t.Errorf("block %d: index has %d txs, want %d", block.NumberU64(), len(b.Txs), len(block.Transactions()))
+		}
+	}
+
+	// Transactions by type.
+	deployTx := chain[0].Transactions()[0]
+	callTx := chain[1].Transactions()[0]
+	sendTx := chain[1].Transactions()[1]
+	wantTxs := map[string][]common.Hash{
+		"dynamic-fee": {deployTx.Hash()},
+		"legacy":      {callTx.Hash()},
+		"access-list": {sendTx.Hash()},
+	}
+	if len(index.Txs) != len(wantTxs) {
+		t.Errorf("wrong tx types in index: %v", index.Txs)
+	}
+	for name, want := range wantTxs {
+		if got := index.Txs[name]; len(got) != 1 || got[0] != want[0] {
+			t.Errorf("wrong %s txs %v, want %v", name, got, want)
+		}
+	}
+
+	// Contracts.
+	deployer := crypto.PubkeyToAddress(w.senders[0].PublicKey)
+	contract := crypto.CreateAddress(deployer, 0)
+	if len(index.Contracts) != 1 {
+		t.Fatalf("wrong contracts %+v", index.Contracts)
+	}
+	c := index.Contracts[0]
+	if c.Address != contract || c.Block != 1 || c.Tx != deployTx.Hash() || c.Deployer != deployer {
+		t.Errorf("wrong contract %+v", c)
+	}
+
+	// Logs.
+	if len(index.Logs) != 1 {
+		t.Fatalf("wrong logs %+v", index.Logs)
+	}
+	l := index.Logs[0]
+	if l.Block != 2 || l.Tx != callTx.Hash() || l.Address != contract {
+		t.Errorf("wrong log %+v", l)
+	}
+	if len(l.Topics) != 1 || l.Topics[0] != common.BigToHash(big.NewInt(0x2a)) {
+		t.Errorf("wrong log topics %v", l.Topics)
+	}
+
+	// Storage.
+	if len(index.Storage) != 1 || index.Storage[contract] != 1 {
+		t.Errorf("wrong accounts with storage %v", index.Storage)
+	}
+
+	// State dumps.
+	for _, block := range chain {
+		file := filepath.Join(out, "state", fmt.Sprintf("%d.json", block.NumberU64()))
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dump state.Dump
+		if err := json.Unmarshal(data, &dump); err != nil {
+			t.Fatalf("block %d: %v", block.NumberU64(), err)
+		}
+		if dump.Root != fmt.Sprintf("%x", block.Root()) {
+			t.Errorf("block %d: state dump has root %s", block.NumberU64(), dump.Root)
+		}
+		// The contract is deployed in block 1, and its slot is written in block 2.
+		account, ok := dump.Accounts[contract]
+		if !ok {
+			t.Fatalf("block %d: contract missing in state dump", block.NumberU64())
+		}
+		if !bytes.Equal(account.Code, common.FromHex("0x6001600055602a60006000a100")) {
+			t.Errorf("block %d: wrong contract code %x", block.NumberU64(), account.Code)
+		}
+		wantSlots := 1
+		if block.NumberU64() == 1 {
+			wantSlots = 0
+		}
+		if len(account.Storage) != wantSlots {
+			t.Errorf("block %d: contract has %d storage slots, want %d", block.NumberU64(), len(account.Storage), wantSlots)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "state", "4.json")); !os.IsNotExist(err) {
+		t.Errorf("state dump beyond the chain head: %v", err)
+	}
+}
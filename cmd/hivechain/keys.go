@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// defaultDerivationPath is the base path of accounts derived from a mnemonic.
+const defaultDerivationPath = "m/44'/60'/0'/0"
+
+// mnemonicSeed computes the BIP-39 seed of a mnemonic. Note the mnemonic is not checked
+// against the word list, and must already be in normalized (NFKD) form, which is the
+// case for English mnemonics.
+func mnemonicSeed(mnemonic, passphrase string) []byte {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
+
+// deriveKey derives the private key at the given BIP-32 path, e.g. "m/44'/60'/0'/0/1".
+func deriveKey(seed []byte, path string) (*ecdsa.PrivateKey, error) {
+	indexes, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+
+	n := crypto.S256().Params().N
+	for _, index := range indexes {
+		var data []byte
+		if index >= 1<<31 {
+			data = append([]byte{0}, crypto.FromECDSA(mustToECDSA(key))...)
+		} else {
+			data = crypto.CompressPubkey(&mustToECDSA(key).PublicKey)
+		}
+		var enc [4]byte
+		binary.BigEndian.PutUint32(enc[:], index)
+		data = append(data, enc[:]...)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		child := new(big.Int).SetBytes(sum[:32])
+		if child.Cmp(n) >= 0 {
+			return nil, errors.New("invalid derived key")
+		}
+		key = child.Add(child, key).Mod(child, n)
+		if key.Sign() == 0 {
+			return nil, errors.New("invalid derived key")
+		}
+		chainCode = sum[32:]
+	}
+	return mustToECDSA(key), nil
+}
+
+func mustToECDSA(k *big.Int) *ecdsa.PrivateKey {
+	key, err := crypto.ToECDSA(k.FillBytes(make([]byte, 32)))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// parseDerivationPath parses a BIP-32 path. Hardened indexes are marked by "'".
+func parseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m/", path)
+	}
+	var indexes []uint32
+	for _, p := range parts[1:] {
+		hardened := strings.HasSuffix(p, "'")
+		n, err := strconv.ParseUint(strings.TrimSuffix(p, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: bad index %q", path, p)
+		}
+		index := uint32(n)
+		if hardened {
+			index += 1 << 31
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Test vector 1 of BIP-32.
+func TestDeriveKeyBIP32(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		path, key string
+	}{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	}
+	for _, test := range tests {
+		key, err := deriveKey(seed, test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		if k := hex.EncodeToString(crypto.FromECDSA(key)); k != test.key {
+			t.Errorf("%s: wrong key %s, want %s", test.path, k, test.key)
+		}
+	}
+}
+
+// This checks the accounts of a well-known development mnemonic.
+func TestMnemonicAccounts(t *testing.T) {
+	seed := mnemonicSeed("test test test test test test test test test test test junk", "")
+	want := []string{
+		"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		"0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+	}
+	for i, addr := range want {
+		path := defaultDerivationPath + "/" + string(rune('0'+i))
+		key, err := deriveKey(seed, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if a := crypto.PubkeyToAddress(key.PublicKey).Hex(); a != addr {
+			t.Errorf("%s: wrong address %s, want %s", path, a, addr)
+		}
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	indexes, err := parseDerivationPath("m/44'/60'/0'/0/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{44 + 1<<31, 60 + 1<<31, 1 << 31, 0, 1}
+	for i := range want {
+		if i >= len(indexes) || indexes[i] != want[i] {
+			t.Fatalf("wrong indexes %v, want %v", indexes, want)
+		}
+	}
+	for _, path := range []string{"", "44'/60'", "m/x", "m/2147483648", "m/-1"} {
+		if _, err := parseDerivationPath(path); err == nil {
+			t.Errorf("no error for invalid path %q", path)
+		}
+	}
+}
@@ -4,6 +4,33 @@
 //
 //	hivechain generate -length 10 -genesis ./genesis.json -blocktime 30 -output .
 //
+// Besides the chain, it writes the post-state and an index of the chain (chain_index.json)
+// listing block and transaction hashes, logs and contracts.
+//
+// By default, simple transactions are added every few blocks. Use -workload to define
+// the transactions in a YAML file:
+//
+//	hivechain generate -genesis ./genesis.json -workload ./workload.yaml -output .
+//
+// The 'fork' subcommand creates a side chain branching off an existing chain. Both
+// branches are written to the output directory, along with a fork.json manifest:
+//
+//	hivechain fork -genesis ./genesis.json -at 5 -length 10 -output . chain.rlp
+//
+// The 'verify' subcommand imports a chain and reports the first invalid block:
+//
+//	hivechain verify -genesis ./genesis.json chain.rlp
+//
+// The 'diff' subcommand reports the first diverging block of two chains:
+//
+//	hivechain diff a.rlp b.rlp
+//
+// The 'export' subcommand converts a range of blocks in a chain.rlp file to engine API
+// payloads (JSON) or Era1 archives:
+//
+//	hivechain export -format payloads -from 10 chain.rlp payloads.json
+//	hivechain export -format era -genesis ./genesis.json chain.rlp ./era
+//
 // The 'print' subcommand displays blocks in a chain.rlp file:
 //
 //	hivechain print -v chain.rlp
@@ -31,7 +58,7 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
-const usage = "Usage: hivechain generate|print|print-genesis|trim [ options ] ..."
+const usage = "Usage: hivechain generate|fork|verify|diff|export|print|print-genesis|trim [ options ] ..."
 
 func main() {
 	// Initialize go-ethereum logging.
@@ -45,6 +72,14 @@ func main() {
 	switch os.Args[1] {
 	case "generate":
 		generateCommand(os.Args[2:])
+	case "fork":
+		forkCommand(os.Args[2:])
+	case "verify":
+		verifyCommand(os.Args[2:])
+	case "diff":
+		diffCommand(os.Args[2:])
+	case "export":
+		exportCommand(os.Args[2:])
 	case "print":
 		printCommand(os.Args[2:])
 	case "print-genesis":
@@ -56,6 +91,122 @@ func main() {
 	}
 }
 
+// verifyCommand checks the validity of a chain.rlp file.
+func verifyCommand(args []string) {
+	var (
+		cfg     generatorConfig
+		genesis = flag.String("genesis", "", "The path and filename to the source genesis.json")
+		seal    = flag.Bool("seal", false, "Verify ethash seals of PoW blocks")
+	)
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		fatalf("Usage: hivechain verify [ options ] <chain.rlp>")
+	}
+	if *genesis == "" {
+		fatalf("Missing -genesis option, please supply a genesis.json file.")
+	}
+	if *seal {
+		cfg.powMode = ethash.ModeNormal
+	} else {
+		cfg.powMode = ethash.ModeFake
+	}
+
+	gspec, err := loadGenesis(*genesis)
+	if err != nil {
+		fatal(err)
+	}
+	cfg.genesis = *gspec
+	chain, err := readChain(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	if err := cfg.verifyChain(chain); err != nil {
+		fatal(err)
+	}
+}
+
+// diffCommand compares two chain.rlp files.
+func diffCommand(args []string) {
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 2 {
+		fatalf("Usage: hivechain diff <a.rlp> <b.rlp>")
+	}
+	a, err := readChain(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	b, err := readChain(flag.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+	if diffChains(a, b) {
+		os.Exit(1)
+	}
+}
+
+// exportCommand writes blocks of a chain.rlp file in another format.
+func exportCommand(args []string) {
+	var (
+		cfg     generatorConfig
+		format  = flag.String("format", "payloads", "Output format (payloads, era)")
+		from    = flag.Uint("from", 0, "Start of block range to output")
+		to      = flag.Uint("to", 0, "End of block range to output (0 = all blocks)")
+		genesis = flag.String("genesis", "", "The path and filename to the source genesis.json (required for era)")
+		network = flag.String("network", "hive", "Network name used in era file names")
+	)
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 2 {
+		fatalf("Usage: hivechain export [ options ] <chain.rlp> <output>")
+	}
+	if *to > 0 && *to <= *from {
+		fatalf("-to must be greater than -from")
+	}
+
+	chain, err := readChain(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	end := uint(len(chain))
+	if *to > 0 && *to < end {
+		end = *to
+	}
+	if *from >= end {
+		fatalf("no blocks in range")
+	}
+	blocks := chain[*from:end]
+
+	switch *format {
+	case "payloads":
+		err = writePayloads(flag.Arg(1), blocks)
+	case "era":
+		// Era files need receipts and total difficulty, so the chain is imported.
+		if *genesis == "" {
+			fatalf("Missing -genesis option, please supply a genesis.json file.")
+		}
+		gspec, err := loadGenesis(*genesis)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.genesis = *gspec
+		cfg.powMode = ethash.ModeFullFake
+		blockchain, err := cfg.importChain(chain)
+		if err != nil {
+			fatal(err)
+		}
+		defer blockchain.Stop()
+		// Epoch zero starts at the genesis block.
+		if blocks[0].NumberU64() == 1 {
+			blocks = append([]*types.Block{blockchain.Genesis()}, blocks...)
+		}
+		err = writeEra(flag.Arg(1), *network, blockchain, blocks)
+	default:
+		fatalf("unknown format %q", *format)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
 // printCommand displays the blocks in a chain.rlp file.
 func printCommand(args []string) {
 	var (
@@ -155,17 +306,19 @@ func trimCommand(args []string) {
 // generateCommand generates a test chain.
 func generateCommand(args []string) {
 	var (
-		cfg     generatorConfig
-		genesis = flag.String("genesis", "", "The path and filename to the source genesis.json")
-		outdir  = flag.String("output", ".", "Chain destination folder")
-		mine    = flag.Bool("mine", false, "Enables ethash mining")
-		pos     = flag.Bool("pos", false, "Enables PoS chain")
+		cfg      generatorConfig
+		genesis  = flag.String("genesis", "", "The path and filename to the source genesis.json")
+		outdir   = flag.String("output", ".", "Chain destination folder")
+		mine     = flag.Bool("mine", false, "Enables ethash mining")
+		pos      = flag.Bool("pos", false, "Enables PoS chain")
+		workload = flag.String("workload", "", "YAML file defining the transactions to add (overrides -tx-interval and -tx-count)")
 	)
 	flag.IntVar(&cfg.blockCount, "length", 2, "The length of the pow chain to generate")
 	flag.IntVar(&cfg.posBlockCount, "poslength", 2, "The length of the pos chain to generate")
 	flag.IntVar(&cfg.blockTimeSec, "blocktime", 30, "The desired block time in seconds")
 	flag.IntVar(&cfg.txInterval, "tx-interval", 10, "Add transactions to chain every n blocks")
 	flag.IntVar(&cfg.txCount, "tx-count", 1, "Maximum number of txs per block")
+	flag.BoolVar(&cfg.stateDumps, "statedumps", false, "Write the state of every block to the 'state' directory")
 	flag.CommandLine.Parse(args)
 
 	if *genesis == "" {
@@ -184,12 +337,66 @@ func generateCommand(args []string) {
 		fatal(err)
 	}
 	cfg.genesis = *gspec
+	if *workload != "" {
+		if cfg.workload, err = loadWorkload(*workload, &cfg.genesis); err != nil {
+			fatal(err)
+		}
+	}
 
 	if err := cfg.writeTestChain(*outdir); err != nil {
 		fatal(err)
 	}
 }
 
+// forkCommand generates a side chain of an existing chain.
+func forkCommand(args []string) {
+	var (
+		cfg      generatorConfig
+		genesis  = flag.String("genesis", "", "The path and filename to the source genesis.json")
+		outdir   = flag.String("output", ".", "Destination folder of the branches and manifest")
+		mine     = flag.Bool("mine", false, "Enables ethash mining")
+		forkAt   = flag.Uint64("at", 0, "Number of the last common block of both branches")
+		extra    = flag.String("extra", "hivechain fork", "Header extra data of the fork blocks")
+		workload = flag.String("workload", "", "YAML file defining the transactions to add (overrides -tx-interval and -tx-count)")
+	)
+	flag.IntVar(&cfg.blockCount, "length", 2, "The number of blocks to generate after the fork point")
+	flag.IntVar(&cfg.blockTimeSec, "blocktime", 0, "The desired block time in seconds (default: differ from the main chain)")
+	flag.IntVar(&cfg.txInterval, "tx-interval", 10, "Add transactions to chain every n blocks")
+	flag.IntVar(&cfg.txCount, "tx-count", 1, "Maximum number of txs per block")
+	flag.CommandLine.Parse(args)
+
+	if flag.NArg() != 1 {
+		fatalf("Usage: hivechain fork [ options ] <chain.rlp>")
+	}
+	if *genesis == "" {
+		fatalf("Missing -genesis option, please supply a genesis.json file.")
+	}
+	if *mine {
+		cfg.powMode = ethash.ModeNormal
+	} else {
+		cfg.powMode = ethash.ModeFullFake
+	}
+	cfg.extra = []byte(*extra)
+
+	gspec, err := loadGenesis(*genesis)
+	if err != nil {
+		fatal(err)
+	}
+	cfg.genesis = *gspec
+	if *workload != "" {
+		if cfg.workload, err = loadWorkload(*workload, &cfg.genesis); err != nil {
+			fatal(err)
+		}
+	}
+	chain, err := readChain(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	if err := cfg.writeFork(*outdir, chain, *forkAt); err != nil {
+		fatal(err)
+	}
+}
+
 func fatalf(format string, args ...interface{}) {
 	fatal(fmt.Errorf(format, args...))
 }
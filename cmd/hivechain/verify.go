@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// verifyChain imports the chain and reports the first invalid block.
+func (cfg generatorConfig) verifyChain(chain []*types.Block) error {
+	blockchain, err := cfg.importChain(chain)
+	if err != nil {
+		return err
+	}
+	defer blockchain.Stop()
+	head := blockchain.CurrentBlock()
+	td := blockchain.GetTd(head.Hash(), head.NumberU64())
+	fmt.Printf("chain is valid: head %d (%x), total difficulty %v\n", head.NumberU64(), head.Hash(), td)
+	return nil
+}
+
+// importChain creates a blockchain containing the given blocks. The blocks may start
+// with the genesis block.
+func (cfg generatorConfig) importChain(chain []*types.Block) (*core.BlockChain, error) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := cfg.genesis.MustCommit(db)
+
+	// Files with the genesis block (chain_genesis.rlp) are accepted.
+	if len(chain) > 0 && chain[0].NumberU64() == 0 {
+		if chain[0].Hash() != genesis.Hash() {
+			return nil, fmt.Errorf("genesis mismatch: chain has %x, genesis.json has %x", chain[0].Hash(), genesis.Hash())
+		}
+		chain = chain[1:]
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("chain has no blocks")
+	}
+
+	setTerminalDifficulty(cfg.genesis.Config, genesis, chain)
+	blockchain, err := core.NewBlockChain(db, nil, &cfg.genesis, nil, cfg.engine(), vm.Config{}, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create blockchain: %v", err)
+	}
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		blockchain.Stop()
+		b := chain[i]
+		return nil, fmt.Errorf("invalid block %d (%x): %v", b.NumberU64(), b.Hash(), err)
+	}
+	return blockchain, nil
+}
+
+// setTerminalDifficulty configures the TTD for chains containing PoS blocks. If the
+// genesis doesn't have a TTD, it is set to the total difficulty at the last PoW block.
+func setTerminalDifficulty(config *params.ChainConfig, genesis *types.Block, chain []*types.Block) {
+	if config.TerminalTotalDifficulty != nil {
+		return
+	}
+	for i, b := range chain {
+		if b.Difficulty().Sign() == 0 {
+			config.TerminalTotalDifficulty = totalDifficulty(genesis, chain[:i])
+			fmt.Printf("chain contains PoS blocks, using terminal total difficulty %v\n", config.TerminalTotalDifficulty)
+			return
+		}
+	}
+}
+
+// diffChains compares two chains by block number. It prints the first diverging block
+// and returns true if the chains differ.
+func diffChains(a, b []*types.Block) bool {
+	// Align the chains by block number, e.g. when comparing chain.rlp to
+	// chain_genesis.rlp.
+	for len(a) > 0 && len(b) > 0 && a[0].NumberU64() != b[0].NumberU64() {
+		if a[0].NumberU64() < b[0].NumberU64() {
+			a = a[1:]
+		} else {
+			b = b[1:]
+		}
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Hash() == b[i].Hash() {
+			continue
+		}
+		fmt.Printf("chains diverge at block %d: %x != %x\n", a[i].NumberU64(), a[i].Hash(), b[i].Hash())
+		for _, d := range headerDiff(a[i].Header(), b[i].Header()) {
+			fmt.Println(d)
+		}
+		if ta, tb := txHashes(a[i]), txHashes(b[i]); !reflect.DeepEqual(ta, tb) {
+			fmt.Printf("transactions: %d != %d\n", len(ta), len(tb))
+		}
+		if len(a[i].Uncles()) != len(b[i].Uncles()) {
+			fmt.Printf("uncles: %d != %d\n", len(a[i].Uncles()), len(b[i].Uncles()))
+		}
+		return true
+	}
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		fmt.Println("chains have no common blocks")
+	case len(a) > len(b):
+		fmt.Printf("chains are equal up to block %d, first chain has %d more blocks\n", b[len(b)-1].NumberU64(), len(a)-len(b))
+	case len(b) > len(a):
+		fmt.Printf("chains are equal up to block %d, second chain has %d more blocks\n", a[len(a)-1].NumberU64(), len(b)-len(a))
+	default:
+		fmt.Println("chains are equal")
+		return false
+	}
+	return true
+}
+
+// headerDiff returns the differing header fields.
+func headerDiff(a, b *types.Header) []string {
+	fa, fb := headerFields(a), headerFields(b)
+	keys := make([]string, 0, len(fa))
+	for k := range fa {
+		keys = append(keys, k)
+	}
+	for k := range fb {
+		if _, ok := fa[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var diffs []string
+	for _, k := range keys {
+		if !reflect.DeepEqual(fa[k], fb[k]) {
+			diffs = append(diffs, fmt.Sprintf("%s: %v != %v", k, fa[k], fb[k]))
+		}
+	}
+	return diffs
+}
+
+func headerFields(h *types.Header) map[string]interface{} {
+	enc, err := json.Marshal(h)
+	if err != nil {
+		panic(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		panic(err)
+	}
+	delete(fields, "hash")
+	return fields
+}
+
+func txHashes(b *types.Block) []string {
+	hashes := make([]string, len(b.Transactions()))
+	for i, tx := range b.Transactions() {
+		hashes[i] = tx.Hash().Hex()
+	}
+	return hashes
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestVerifyChain(t *testing.T) {
+	dir := t.TempDir()
+	cfg := generatorConfig{
+		blockCount:   5,
+		blockTimeSec: 30,
+		powMode:      ethash.ModeFullFake,
+		genesis:      testGenesis(),
+	}
+	if err := cfg.writeTestChain(dir); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := readChain(filepath.Join(dir, "chain.rlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyCfg := generatorConfig{powMode: ethash.ModeFake, genesis: testGenesis()}
+	if err := verifyCfg.verifyChain(chain); err != nil {
+		t.Fatalf("valid chain rejected: %v", err)
+	}
+
+	// Headers are checked even though seals aren't.
+	header := chain[len(chain)-1].Header()
+	header.Difficulty = new(big.Int).Add(header.Difficulty, big.NewInt(1))
+	chain[len(chain)-1] = types.NewBlockWithHeader(header)
+	verifyCfg = generatorConfig{powMode: ethash.ModeFake, genesis: testGenesis()}
+	if err := verifyCfg.verifyChain(chain); err == nil {
+		t.Fatal("chain with invalid difficulty accepted")
+	}
+}
@@ -0,0 +1,749 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/yaml.v3"
+)
+
+// workloadSpec defines the transactions added to a generated chain. It is loaded from
+// the YAML file given by 'hivechain generate -workload'.
+type workloadSpec struct {
+	Seed      int64          `yaml:"seed"` // seeds the random recipients of transfers
+	Senders   []senderSpec   `yaml:"senders"`
+	Contracts []contractSpec `yaml:"contracts"`
+	Txs       []txSpec       `yaml:"txs"`
+	Blocks    []blockSpec    `yaml:"blocks"`
+}
+
+// senderSpec is a private key, or a mnemonic from which count keys are derived.
+type senderSpec struct {
+	Key        string `yaml:"key"`
+	Mnemonic   string `yaml:"mnemonic"`
+	Passphrase string `yaml:"passphrase"`
+	Path       string `yaml:"path"`  // base derivation path, the account index is appended
+	Count      int    `yaml:"count"` // number of accounts derived from the mnemonic
+}
+
+// contractSpec is contract bytecode. The code can be given inline as hex, or as a file
+// containing hex or binary code. Paths are relative to the workload file.
+type contractSpec struct {
+	Name string `yaml:"name"`
+	Code string `yaml:"code"`
+	File string `yaml:"file"`
+	ABI  string `yaml:"abi"` // ABI JSON file, required for method calls and constructor args
+}
+
+// txSpec is a transaction template.
+type txSpec struct {
+	Name     string        `yaml:"name"`
+	Kind     string        `yaml:"kind"` // transfer, deploy or call
+	Type     string        `yaml:"type"` // legacy, access-list or dynamic-fee
+	To       string        `yaml:"to"`   // recipient address, or "random"
+	Contract string        `yaml:"contract"`
+	Method   string        `yaml:"method"`
+	Args     []interface{} `yaml:"args"`
+	Data     string        `yaml:"data"`
+	Value    *weiValue     `yaml:"value"`
+	Gas      uint64        `yaml:"gas"`
+
+	GasPrice   *weiValue         `yaml:"gas-price"`
+	GasTipCap  *weiValue         `yaml:"gas-tip-cap"`
+	GasFeeCap  *weiValue         `yaml:"gas-fee-cap"`
+	AccessList []accessTupleSpec `yaml:"access-list"`
+}
+
+type accessTupleSpec struct {
+	Address     common.Address `yaml:"address"`
+	StorageKeys []common.Hash  `yaml:"storage-keys"`
+}
+
+// blockSpec adds a mix of transactions to every n-th block in a range.
+type blockSpec struct {
+	From  uint64    `yaml:"from"`
+	To    uint64    `yaml:"to"` // last block, zero means no limit
+	Every uint64    `yaml:"every"`
+	Mix   []mixSpec `yaml:"mix"`
+}
+
+type mixSpec struct {
+	Tx     string `yaml:"tx"`
+	Count  int    `yaml:"count"`
+	Sender *int   `yaml:"sender"` // index in the sender list, senders are rotated if not set
+}
+
+// weiValue is an amount of wei. In YAML, it is a number with an optional unit, e.g.
+// "100", "0x64", "2 gwei" or "0.5 ether".
+type weiValue big.Int
+
+func (v *weiValue) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	n, err := parseWei(s)
+	if err != nil {
+		return err
+	}
+	*v = weiValue(*n)
+	return nil
+}
+
+func (v *weiValue) Int() *big.Int {
+	if v == nil {
+		return nil
+	}
+	return new(big.Int).Set((*big.Int)(v))
+}
+
+func parseWei(s string) (*big.Int, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	unit := big.NewInt(1)
+	if len(fields) == 2 {
+		switch strings.ToLower(fields[1]) {
+		case "wei":
+		case "gwei":
+			unit.SetUint64(params.GWei)
+		case "ether":
+			unit.SetUint64(params.Ether)
+		default:
+			return nil, fmt.Errorf("invalid amount %q: unknown unit %q", s, fields[1])
+		}
+	}
+	if n, ok := new(big.Int).SetString(fields[0], 0); ok && n.Sign() >= 0 {
+		return n.Mul(n, unit), nil
+	}
+	r, ok := new(big.Rat).SetString(fields[0])
+	if ok {
+		r.Mul(r, new(big.Rat).SetInt(unit))
+	}
+	if !ok || r.Sign() < 0 || !r.IsInt() {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return r.Num(), nil
+}
+
+const (
+	defaultGasPrice = params.GWei // gas price and tip used when not configured
+	defaultExecGas  = 1000000     // added to the intrinsic gas of deploy and call txs
+)
+
+// workload generates transactions according to a workloadSpec.
+type workload struct {
+	genesis   *core.Genesis
+	senders   []*ecdsa.PrivateKey
+	contracts map[string]*workloadContract
+	txs       map[string]*workloadTx
+	blocks    []blockSpec
+	next      int        // next sender in rotation
+	rand      *rand.Rand // source of random recipients, seeded for reproducible chains
+}
+
+type workloadContract struct {
+	name    string
+	code    []byte
+	abi     *abi.ABI
+	address *common.Address // latest deployment
+}
+
+type workloadTx struct {
+	spec       *txSpec
+	txType     int // -1 selects the latest type supported by the fork
+	to         *common.Address
+	contract   *workloadContract
+	data       []byte
+	accessList types.AccessList
+}
+
+// loadWorkload reads a workload file.
+func loadWorkload(file string, genesis *core.Genesis) (*workload, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var spec workloadSpec
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid workload file %s: %v", file, err)
+	}
+	w, err := newWorkload(&spec, filepath.Dir(file), genesis)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workload file %s: %v", file, err)
+	}
+	return w, nil
+}
+
+func newWorkload(spec *workloadSpec, dir string, genesis *core.Genesis) (*workload, error) {
+	w := &workload{
+		genesis:   genesis,
+		contracts: make(map[string]*workloadContract),
+		txs:       make(map[string]*workloadTx),
+		blocks:    spec.Blocks,
+		rand:      rand.New(rand.NewSource(spec.Seed)),
+	}
+	if err := w.loadSenders(spec.Senders); err != nil {
+		return nil, err
+	}
+	for _, c := range spec.Contracts {
+		if err := w.loadContract(c, dir); err != nil {
+			return nil, fmt.Errorf("contract %q: %v", c.Name, err)
+		}
+	}
+	for i := range spec.Txs {
+		tx := &spec.Txs[i]
+		if err := w.loadTx(tx); err != nil {
+			return nil, fmt.Errorf("tx %q: %v", tx.Name, err)
+		}
+	}
+	for i := range w.blocks {
+		b := &w.blocks[i]
+		if b.Every == 0 {
+			b.Every = 1
+		}
+		for j := range b.Mix {
+			m := &b.Mix[j]
+			if w.txs[m.Tx] == nil {
+				return nil, fmt.Errorf("block mix references unknown tx %q", m.Tx)
+			}
+			if m.Count == 0 {
+				m.Count = 1
+			}
+			if m.Sender != nil && (*m.Sender < 0 || *m.Sender >= len(w.senders)) {
+				return nil, fmt.Errorf("block mix references unknown sender %d", *m.Sender)
+			}
+		}
+	}
+	return w, nil
+}
+
+func (w *workload) loadSenders(specs []senderSpec) error {
+	for i, s := range specs {
+		switch {
+		case s.Key != "" && s.Mnemonic != "":
+			return fmt.Errorf("sender %d: key and mnemonic are mutually exclusive", i)
+		case s.Key != "":
+			key, err := crypto.HexToECDSA(strings.TrimPrefix(s.Key, "0x"))
+			if err != nil {
+				return fmt.Errorf("sender %d: %v", i, err)
+			}
+			w.senders = append(w.senders, key)
+		case s.Mnemonic != "":
+			path := s.Path
+			if path == "" {
+				path = defaultDerivationPath
+			}
+			count := s.Count
+			if count == 0 {
+				count = 1
+			}
+			seed := mnemonicSeed(s.Mnemonic, s.Passphrase)
+			for j := 0; j < count; j++ {
+				key, err := deriveKey(seed, fmt.Sprintf("%s/%d", path, j))
+				if err != nil {
+					return fmt.Errorf("sender %d: %v", i, err)
+				}
+				w.senders = append(w.senders, key)
+			}
+		default:
+			return fmt.Errorf("sender %d: needs key or mnemonic", i)
+		}
+	}
+	if len(specs) == 0 {
+		// Use the known accounts which have balance in genesis. They are sorted by
+		// address to keep the sender rotation, and thus the chain, reproducible.
+		var addrs []common.Address
+		for addr := range knownAccounts {
+			if _, ok := w.genesis.Alloc[addr]; ok {
+				addrs = append(addrs, addr)
+			}
+		}
+		sort.Slice(addrs, func(i, j int) bool {
+			return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+		})
+		for _, addr := range addrs {
+			w.senders = append(w.senders, knownAccounts[addr])
+		}
+	}
+	if len(w.senders) == 0 {
+		return fmt.Errorf("no senders")
+	}
+	return nil
+}
+
+func (w *workload) loadContract(spec contractSpec, dir string) error {
+	if spec.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if w.contracts[spec.Name] != nil {
+		return fmt.Errorf("duplicate name")
+	}
+	c := &workloadContract{name: spec.Name}
+	switch {
+	case spec.Code != "" && spec.File != "":
+		return fmt.Errorf("code and file are mutually exclusive")
+	case spec.Code != "":
+		code, err := decodeHex(spec.Code)
+		if err != nil {
+			return fmt.Errorf("invalid code: %v", err)
+		}
+		c.code = code
+	case spec.File != "":
+		content, err := os.ReadFile(resolvePath(dir, spec.File))
+		if err != nil {
+			return err
+		}
+		// Files produced by solc --bin contain hex, anything else is taken as binary code.
+		if code, err := decodeHex(string(content)); err == nil {
+			c.code = code
+		} else {
+			c.code = content
+		}
+	default:
+		return fmt.Errorf("needs code or file")
+	}
+	if spec.ABI != "" {
+		f, err := os.Open(resolvePath(dir, spec.ABI))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		parsed, err := abi.JSON(f)
+		if err != nil {
+			return fmt.Errorf("invalid ABI: %v", err)
+		}
+		c.abi = &parsed
+	}
+	w.contracts[spec.Name] = c
+	return nil
+}
+
+func (w *workload) loadTx(spec *txSpec) error {
+	if spec.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if w.txs[spec.Name] != nil {
+		return fmt.Errorf("duplicate name")
+	}
+	tx := &workloadTx{spec: spec, txType: -1}
+	switch spec.Type {
+	case "":
+	case "legacy":
+		tx.txType = types.LegacyTxType
+	case "access-list":
+		tx.txType = types.AccessListTxType
+	case "dynamic-fee":
+		tx.txType = types.DynamicFeeTxType
+	default:
+		return fmt.Errorf("unknown type %q", spec.Type)
+	}
+	if spec.Type == "legacy" && len(spec.AccessList) > 0 {
+		return fmt.Errorf("legacy transactions can't have an access list")
+	}
+	for _, t := range spec.AccessList {
+		tx.accessList = append(tx.accessList, types.AccessTuple{Address: t.Address, StorageKeys: t.StorageKeys})
+	}
+	if spec.Data != "" {
+		data, err := decodeHex(spec.Data)
+		if err != nil {
+			return fmt.Errorf("invalid data: %v", err)
+		}
+		tx.data = data
+	}
+	if spec.To != "" && spec.To != "random" {
+		if !common.IsHexAddress(spec.To) {
+			return fmt.Errorf("invalid address %q", spec.To)
+		}
+		to := common.HexToAddress(spec.To)
+		tx.to = &to
+	}
+	if spec.Contract != "" {
+		if tx.contract = w.contracts[spec.Contract]; tx.contract == nil {
+			return fmt.Errorf("unknown contract %q", spec.Contract)
+		}
+	}
+
+	switch spec.Kind {
+	case "transfer":
+		if spec.To == "" {
+			return fmt.Errorf("transfer needs recipient")
+		}
+	case "deploy":
+		if tx.contract == nil {
+			return fmt.Errorf("deploy needs contract")
+		}
+		if spec.To != "" {
+			return fmt.Errorf("deploy can't have recipient")
+		}
+		input := append([]byte{}, tx.contract.code...)
+		if len(spec.Args) > 0 {
+			if tx.contract.abi == nil {
+				return fmt.Errorf("constructor args need contract ABI")
+			}
+			args, err := abiArgs(tx.contract.abi.Constructor.Inputs, spec.Args)
+			if err != nil {
+				return fmt.Errorf("constructor: %v", err)
+			}
+			packed, err := tx.contract.abi.Pack("", args...)
+			if err != nil {
+				return fmt.Errorf("constructor: %v", err)
+			}
+			input = append(input, packed...)
+		}
+		tx.data = append(input, tx.data...)
+	case "call":
+		if tx.contract == nil && tx.to == nil {
+			return fmt.Errorf("call needs contract or recipient")
+		}
+		if spec.Method != "" {
+			if spec.Data != "" {
+				return fmt.Errorf("method and data are mutually exclusive")
+			}
+			if tx.contract == nil || tx.contract.abi == nil {
+				return fmt.Errorf("method call needs contract ABI")
+			}
+			method, ok := tx.contract.abi.Methods[spec.Method]
+			if !ok {
+				return fmt.Errorf("contract %q has no method %q", spec.Contract, spec.Method)
+			}
+			args, err := abiArgs(method.Inputs, spec.Args)
+			if err != nil {
+				return fmt.Errorf("method %s: %v", spec.Method, err)
+			}
+			if tx.data, err = tx.contract.abi.Pack(spec.Method, args...); err != nil {
+				return fmt.Errorf("method %s: %v", spec.Method, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", spec.Kind)
+	}
+	w.txs[spec.Name] = tx
+	return nil
+}
+
+// addTxs adds the transactions configured for the block.
+func (w *workload) addTxs(gen *core.BlockGen, gasLimit uint64) {
+	var (
+		num     = gen.Number().Uint64()
+		gasUsed uint64
+	)
+	for _, b := range w.blocks {
+		if num < b.From || (b.To != 0 && num > b.To) || (num-b.From)%b.Every != 0 {
+			continue
+		}
+		for _, m := range b.Mix {
+			for i := 0; i < m.Count; i++ {
+				if !w.addTx(gen, w.txs[m.Tx], m.Sender, &gasUsed, gasLimit) {
+					break
+				}
+			}
+		}
+	}
+}
+
+// addTx adds a single transaction. It returns false if the transaction could not be
+// added because the block is full or no sender has enough balance.
+func (w *workload) addTx(gen *core.BlockGen, wtx *workloadTx, sender *int, gasUsed *uint64, gasLimit uint64) bool {
+	candidates := w.senders
+	if sender != nil {
+		candidates = w.senders[*sender : *sender+1]
+	}
+	for range candidates {
+		var key *ecdsa.PrivateKey
+		if sender != nil {
+			key = candidates[0]
+		} else {
+			key = w.senders[w.next%len(w.senders)]
+			w.next++
+		}
+		src := crypto.PubkeyToAddress(key.PublicKey)
+		if gen.GetBalance(src).Sign() == 0 {
+			continue // TxNonce panics for nonexistent accounts.
+		}
+		tx, err := w.makeTx(gen, wtx, key)
+		if err != nil {
+			log.Printf("skipping tx %s in block %d: %v", wtx.spec.Name, gen.Number(), err)
+			return false
+		}
+		// Check if account has enough balance left to cover the tx.
+		if gen.GetBalance(src).Cmp(tx.Cost()) < 0 {
+			continue
+		}
+		// Check if block gas limit reached.
+		if *gasUsed+tx.Gas() > gasLimit {
+			log.Printf("block %d is full, skipping tx %s", gen.Number(), wtx.spec.Name)
+			return false
+		}
+
+		log.Printf("adding tx %s (type %d) from %s in block %d", wtx.spec.Name, tx.Type(), src, gen.Number())
+		log.Printf("%v (%d gas)", tx.Hash(), tx.Gas())
+		gen.AddTx(tx)
+		*gasUsed += tx.Gas()
+		if tx.To() == nil && wtx.contract != nil {
+			addr := crypto.CreateAddress(src, tx.Nonce())
+			wtx.contract.address = &addr
+		}
+		return true
+	}
+	log.Printf("no sender can pay for tx %s in block %d", wtx.spec.Name, gen.Number())
+	return false
+}
+
+// makeTx creates a signed transaction from a template.
+func (w *workload) makeTx(gen *core.BlockGen, wtx *workloadTx, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	var (
+		spec    = wtx.spec
+		config  = w.genesis.Config
+		num     = gen.Number()
+		src     = crypto.PubkeyToAddress(key.PublicKey)
+		nonce   = gen.TxNonce(src)
+		value   = new(big.Int)
+		baseFee *big.Int
+		to      = wtx.to
+	)
+	if config.IsLondon(num) {
+		baseFee = gen.BaseFee()
+	}
+	if spec.Value != nil {
+		value = spec.Value.Int()
+	}
+	switch {
+	case spec.Kind == "transfer" && spec.To == "random":
+		var dst common.Address
+		w.rand.Read(dst[:])
+		to = &dst
+	case spec.Kind == "call" && to == nil:
+		if wtx.contract.address == nil {
+			return nil, fmt.Errorf("contract %s is not deployed", wtx.contract.name)
+		}
+		to = wtx.contract.address
+	}
+
+	gas := spec.Gas
+	if gas == 0 {
+		igas, err := core.IntrinsicGas(wtx.data, wtx.accessList, to == nil, config.IsHomestead(num), config.IsIstanbul(num))
+		if err != nil {
+			return nil, err
+		}
+		gas = igas
+		if spec.Kind != "transfer" {
+			gas += defaultExecGas
+		}
+	}
+
+	txType := wtx.txType
+	if txType < 0 {
+		switch {
+		case config.IsLondon(num):
+			txType = types.DynamicFeeTxType
+		case config.IsBerlin(num):
+			txType = types.AccessListTxType
+		default:
+			txType = types.LegacyTxType
+		}
+	}
+	if txType == types.AccessListTxType && !config.IsBerlin(num) {
+		return nil, fmt.Errorf("access-list transactions require Berlin")
+	}
+	if txType == types.DynamicFeeTxType && !config.IsLondon(num) {
+		return nil, fmt.Errorf("dynamic-fee transactions require London")
+	}
+
+	// The gas price is raised to the base fee if it is lower.
+	gasPrice := big.NewInt(defaultGasPrice)
+	if spec.GasPrice != nil {
+		gasPrice = spec.GasPrice.Int()
+	}
+	if baseFee != nil && gasPrice.Cmp(baseFee) < 0 {
+		gasPrice.Set(baseFee)
+	}
+
+	var inner types.TxData
+	switch txType {
+	case types.LegacyTxType:
+		inner = &types.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: gas, To: to, Value: value, Data: wtx.data}
+	case types.AccessListTxType:
+		inner = &types.AccessListTx{
+			ChainID:    config.ChainID,
+			Nonce:      nonce,
+			GasPrice:   gasPrice,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       wtx.data,
+			AccessList: wtx.accessList,
+		}
+	case types.DynamicFeeTxType:
+		tip := big.NewInt(defaultGasPrice)
+		if spec.GasTipCap != nil {
+			tip = spec.GasTipCap.Int()
+		}
+		feeCap := new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip)
+		if spec.GasFeeCap != nil {
+			feeCap = spec.GasFeeCap.Int()
+		}
+		if feeCap.Cmp(baseFee) < 0 {
+			feeCap.Set(baseFee)
+		}
+		if tip.Cmp(feeCap) > 0 {
+			tip.Set(feeCap)
+		}
+		inner = &types.DynamicFeeTx{
+			ChainID:    config.ChainID,
+			Nonce:      nonce,
+			GasTipCap:  tip,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       wtx.data,
+			AccessList: wtx.accessList,
+		}
+	}
+	return types.SignNewTx(key, types.MakeSigner(config, num), inner)
+}
+
+// abiArgs converts YAML values to the Go types expected by the ABI encoder.
+func abiArgs(inputs abi.Arguments, values []interface{}) ([]interface{}, error) {
+	if len(values) != len(inputs) {
+		return nil, fmt.Errorf("got %d args, want %d", len(values), len(inputs))
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		arg, err := abiValue(inputs[i].Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("arg %d (%s): %v", i, inputs[i].Type, err)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+func abiValue(t abi.Type, v interface{}) (interface{}, error) {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		n, err := abiInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if t.T == abi.UintTy && (n.Sign() < 0 || n.BitLen() > t.Size) {
+			return nil, fmt.Errorf("value %v out of range", n)
+		}
+		if t.T == abi.IntTy && (n.Sign() >= 0 && n.BitLen() >= t.Size || n.Sign() < 0 && new(big.Int).Add(n, common.Big1).BitLen() >= t.Size) {
+			return nil, fmt.Errorf("value %v out of range", n)
+		}
+		typ := t.GetType()
+		switch {
+		case typ == reflect.TypeOf(n):
+			return n, nil
+		case t.T == abi.UintTy:
+			return reflect.ValueOf(n.Uint64()).Convert(typ).Interface(), nil
+		default:
+			return reflect.ValueOf(n.Int64()).Convert(typ).Interface(), nil
+		}
+	case abi.BoolTy:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("want boolean")
+	case abi.StringTy:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	case abi.AddressTy:
+		if s, ok := v.(string); ok && common.IsHexAddress(s) {
+			return common.HexToAddress(s), nil
+		}
+		return nil, fmt.Errorf("want hex address")
+	case abi.BytesTy, abi.FixedBytesTy:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("want hex bytes")
+		}
+		b, err := decodeHex(s)
+		if err != nil {
+			return nil, err
+		}
+		if t.T == abi.BytesTy {
+			return b, nil
+		}
+		if len(b) != t.Size {
+			return nil, fmt.Errorf("want %d bytes, got %d", t.Size, len(b))
+		}
+		arr := reflect.New(t.GetType()).Elem()
+		reflect.Copy(arr, reflect.ValueOf(b))
+		return arr.Interface(), nil
+	case abi.SliceTy, abi.ArrayTy:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("want list")
+		}
+		var rv reflect.Value
+		if t.T == abi.SliceTy {
+			rv = reflect.MakeSlice(t.GetType(), len(list), len(list))
+		} else {
+			if len(list) != t.Size {
+				return nil, fmt.Errorf("want %d elements, got %d", t.Size, len(list))
+			}
+			rv = reflect.New(t.GetType()).Elem()
+		}
+		for i, elem := range list {
+			ev, err := abiValue(*t.Elem, elem)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i, err)
+			}
+			rv.Index(i).Set(reflect.ValueOf(ev))
+		}
+		return rv.Interface(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type")
+	}
+}
+
+// abiInt converts an integer argument. Large values must be given as a string.
+func abiInt(v interface{}) (*big.Int, error) {
+	switch v := v.(type) {
+	case int:
+		return big.NewInt(int64(v)), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case string:
+		if n, ok := new(big.Int).SetString(v, 0); ok {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid integer %v (use a string for large values)", v)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	return hex.DecodeString(s)
+}
+
+func resolvePath(dir, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
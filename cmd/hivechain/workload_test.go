@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseWei(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // decimal, empty for errors
+	}{
+		{"100", "100"},
+		{"0x64", "100"},
+		{"100 wei", "100"},
+		{"2 gwei", "2000000000"},
+		{"2 GWei", "2000000000"},
+		{"0.5 ether", "500000000000000000"},
+		{"1.000000001 ether", "1000000001000000000"},
+		{"1e3 gwei", "1000000000000"},
+		{"", ""},
+		{"-1", ""},
+		{"-1 gwei", ""},
+		{"1.5", ""},
+		{"0.1 gwei wei", ""},
+		{"1 finney", ""},
+		{"one ether", ""},
+	}
+	for _, test := range tests {
+		n, err := parseWei(test.input)
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("%q: no error, got %v", test.input, n)
+		case test.want != "" && err != nil:
+			t.Errorf("%q: %v", test.input, err)
+		case test.want != "" && n.String() != test.want:
+			t.Errorf("%q: got %v, want %s", test.input, n, test.want)
+		}
+	}
+}
+
+func TestWeiValueYAML(t *testing.T) {
+	var spec txSpec
+	if err := yaml.Unmarshal([]byte("{value: 0.1 ether, gas-price: 7}"), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if v := spec.Value.Int(); v.String() != "100000000000000000" {
+		t.Errorf("wrong value %v", v)
+	}
+	if v := spec.GasPrice.Int(); v.Int64() != 7 {
+		t.Errorf("wrong gas price %v", v)
+	}
+	if spec.GasTipCap.Int() != nil {
+		t.Error("unset value is not nil")
+	}
+	if err := yaml.Unmarshal([]byte("{value: 1 finney}"), &spec); err == nil {
+		t.Error("no error for invalid unit")
+	}
+}
+
+func TestABIValue(t *testing.T) {
+	addr := "0x703c4b2bD70c169f5717101CaeE543299Fc946C7"
+	tests := []struct {
+		typ   string
+		value interface{}
+		want  interface{} // nil for errors
+	}{
+		{"uint8", 255, uint8(255)},
+		{"uint8", 256, nil},
+		{"uint8", -1, nil},
+		{"int8", 127, int8(127)},
+		{"int8", -128, int8(-128)},
+		{"int8", 128, nil},
+		{"int8", -129, nil},
+		{"uint64", "0xffffffffffffffff", uint64(1<<64 - 1)},
+		{"uint256", "1000000000000000000000", mustBig("1000000000000000000000")},
+		{"int256", -5, big.NewInt(-5)},
+		{"uint256", "foo", nil},
+		{"uint256", 1.5, nil},
+		{"bool", true, true},
+		{"bool", "true", nil},
+		{"string", "hello", "hello"},
+		{"string", 42, "42"},
+		{"address", addr, common.HexToAddress(addr)},
+		{"address", "0x1234", nil},
+		{"bytes", "0x0102", []byte{1, 2}},
+		{"bytes", "0xzz", nil},
+		{"bytes2", "0x0102", [2]byte{1, 2}},
+		{"bytes2", "0x01", nil},
+		{"uint16[]", []interface{}{1, 2}, []uint16{1, 2}},
+		{"uint16[2]", []interface{}{1, 2}, [2]uint16{1, 2}},
+		{"uint16[2]", []interface{}{1}, nil},
+		{"uint16[]", []interface{}{1, -1}, nil},
+		{"uint16[]", 1, nil},
+	}
+	for _, test := range tests {
+		typ, err := abi.NewType(test.typ, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := abiValue(typ, test.value)
+		switch {
+		case test.want == nil && err == nil:
+			t.Errorf("%s %v: no error, got %v", test.typ, test.value, v)
+		case test.want != nil && err != nil:
+			t.Errorf("%s %v: %v", test.typ, test.value, err)
+		case test.want != nil && !reflect.DeepEqual(v, test.want):
+			t.Errorf("%s %v: got %#v, want %#v", test.typ, test.value, v, test.want)
+		}
+	}
+}
+
+func mustBig(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		panic("invalid number " + s)
+	}
+	return n
+}
+
+const testWorkload = `
+seed: 7
+contracts:
+  - name: logs
+    code: "0x4360005260006020525b63000000156300000027565b60206020a15a61271010630000000957005b60205160010160205260406000209056"
+txs:
+  - name: send
+    kind: transfer
+    type: legacy
+    to: random
+    value: 1 gwei
+  - name: deploy
+    kind: deploy
+    contract: logs
+  - name: call
+    kind: call
+    type: access-list
+    contract: logs
+blocks:
+  - from: 1
+    to: 1
+    mix: [{tx: deploy}]
+  - from: 2
+    mix: [{tx: send, count: 3}, {tx: call}]
+`
+
+// TestWorkloadChain generates a chain from a workload file, and checks that the
+// transactions are added and that the chain is reproducible.
+func TestWorkloadChain(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "workload.yaml")
+	if err := os.WriteFile(file, []byte(testWorkload), 0644); err != nil {
+		t.Fatal(err)
+	}
+	genesis := testGenesis()
+	genesis.Alloc = make(core.GenesisAlloc)
+	for addr := range knownAccounts {
+		genesis.Alloc[addr] = core.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+
+	generate := func() []*types.Block {
+		w, err := loadWorkload(file, &genesis)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := t.TempDir()
+		cfg := generatorConfig{
+			blockCount:   4,
+			blockTimeSec: 30,
+			powMode:      ethash.ModeFullFake,
+			genesis:      genesis,
+			workload:     w,
+		}
+		if err := cfg.writeTestChain(out); err != nil {
+			t.Fatal(err)
+		}
+		chain, err := readChain(filepath.Join(out, "chain.rlp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chain
+	}
+	chain := generate()
+
+	// Block 1 deploys the contract, later blocks have three transfers and a call.
+	wantTypes := [][]uint8{
+		{types.DynamicFeeTxType},
+		{types.LegacyTxType, types.LegacyTxType, types.LegacyTxType, types.AccessListTxType},
+		{types.LegacyTxType, types.LegacyTxType, types.LegacyTxType, types.AccessListTxType},
+		{types.LegacyTxType, types.LegacyTxType, types.LegacyTxType, types.AccessListTxType},
+	}
+	if len(chain) != len(wantTypes) {
+		t.Fatalf("wrong chain length %d", len(chain))
+	}
+	signer := types.LatestSigner(genesis.Config)
+	var senders []common.Address
+	for i, block := range chain {
+		var txTypes []uint8
+		for _, tx := range block.Transactions() {
+			txTypes = append(txTypes, tx.Type())
+			sender, err := types.Sender(signer, tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			senders = append(senders, sender)
+		}
+		if !reflect.DeepEqual(txTypes, wantTypes[i]) {
+			t.Errorf("block %d: wrong tx types %v, want %v", block.NumberU64(), txTypes, wantTypes[i])
+		}
+	}
+	deploy := chain[0].Transactions()[0]
+	if deploy.To() != nil {
+		t.Fatal("first tx is not a deployment")
+	}
+	if call := chain[1].Transactions()[3]; call.To() == nil || *call.To() == (common.Address{}) {
+		t.Error("call has no recipient")
+	}
+
+	// Default senders rotate in address order.
+	for i := 1; i < 3; i++ {
+		if bytes.Compare(senders[i-1][:], senders[i][:]) >= 0 {
+			t.Fatalf("senders not in address order: %v", senders[:3])
+		}
+	}
+
+	// Generating the chain again must give the same blocks.
+	again := generate()
+	for i := range chain {
+		if chain[i].Hash() != again[i].Hash() {
+			t.Fatalf("block %d differs between runs", chain[i].NumberU64())
+		}
+	}
+}
@@ -199,15 +199,7 @@ function onFileListing(data, error) {
     }
     */
     let table = $("#filetable")
-    suites = []
-    data.split("\n").forEach(function(elem, index) {
-        if (!elem) {
-            return;
-        }
-        obj = JSON.parse(elem)
-        //suites.push([  obj.start,obj.name , obj.simLog, obj.primaryClient, obj.pass, obj.fileName])
-        suites.push(obj)
-    })
+    suites = parseListing(data)
     filetable = $("#filetable").DataTable({
         data: suites,
         pageLength: 50,
@@ -266,7 +258,8 @@ function onFileListing(data, error) {
                     let size = utils.units(data.size)
                     btn = '<button type="button" class="btn btn-sm btn-primary"><span class="loader" role="status" aria-hidden="true"></span><span class="txt">Load (' + size + ')</span></button>'
                     raw = logview("results/" + data.fileName, "[json]")
-                    return btn + "&nbsp;" + raw
+                    cmp = '<a href="javascript:void(0)" class="compare-select">compare</a>'
+                    return btn + "&nbsp;" + raw + "&nbsp;" + cmp
                 },
             },
         ],
@@ -294,22 +287,118 @@ function onFileListing(data, error) {
         }
         loadTestSuite(fname, onDone);
     });
+
+    // This selects suites for comparison.
+    $('#filetable tbody').on('click', '.compare-select', function() {
+        let data = filetable.row($(this).parents('tr')).data();
+        if (!$("#compare_a").val() || $("#compare_b").val()) {
+            $("#compare_a").val(data.fileName);
+            $("#compare_b").val("");
+            progress("Selected " + data.fileName + " as comparison base, select another run to compare");
+            return;
+        }
+        $("#compare_b").val(data.fileName);
+        openComparePage({"compareA": $("#compare_a").val(), "compareB": data.fileName});
+    });
+    fillCompareLists(suites);
+}
+
+// parseListing parses listing JSON lines.
+function parseListing(data) {
+    let entries = [];
+    data.split("\n").forEach(function(elem) {
+        if (elem) {
+            entries.push(JSON.parse(elem));
+        }
+    });
+    return entries;
+}
+
+// listingFilter holds the query parameters of the suite listing.
+var listingFilter = {};
+
+// loadListing fetches the suite listing. If reset is false, the next page of
+// results is appended to the table.
+function loadListing(reset) {
+    let params = $.extend({}, listingFilter);
+    if (!reset && filetable) {
+        params.offset = filetable.rows().count();
+    }
+    progress("Loading file list...");
+    $.ajax("listing.jsonl?" + $.param(params), {
+        dataType: "text",
+        success: function(data, status, xhr) {
+            if (!filetable) {
+                onFileListing(data);
+            } else {
+                if (reset) {
+                    filetable.clear();
+                }
+                filetable.rows.add(parseListing(data)).draw(false);
+                fillCompareLists(filetable.rows().data().toArray());
+            }
+            // The total is only known when hiveview serves the listing from its index.
+            let total = xhr.getResponseHeader("X-Listing-Total");
+            let loaded = filetable.rows().count();
+            $("#listing_count").text(total ? "Showing " + loaded + " of " + total + " runs." : "");
+            $("#listing_more").toggle(total !== null && loaded < parseInt(total));
+        },
+        error: function(xhr, status, err) {
+            progress("error loading file list: " + (xhr.responseText || err));
+        },
+    });
 }
 
+var filetable = null;
+
 $(document).ready(function() {
     // Retrieve the list of files
-    progress("Loading file list...")
-    $.ajax("listing.jsonl", {
-        success: onFileListing,
-        failure: function(status, err) {
-            alert(err);
-        },
-    })
+    loadListing(true);
+    $("#listing_more").on("click", function() {
+        loadListing(false);
+    });
+    $("#listing_filter").on("submit", function(ev) {
+        ev.preventDefault();
+        listingFilter = {};
+        let fields = {"suite": "#filter_suite", "client": "#filter_client", "from": "#filter_from", "to": "#filter_to", "status": "#filter_status"};
+        for (let key in fields) {
+            let value = $(fields[key]).val();
+            if (value) {
+                listingFilter[key] = value;
+            }
+        }
+        loadListing(true);
+    });
 
     // Handle navigation clicks.
     $(".nav-link").on("click", function(ev) {
         nav.store({"page": ev.target.id});
     });
+    $("#search_form").on("submit", function(ev) {
+        ev.preventDefault();
+        nav.store({"page": "v-pills-search-tab", "q": $("#search_query").val()});
+        runSearch();
+    });
+    $("#v-pills-matrix-tab").on("shown.bs.tab", function() {
+        loadMatrix();
+    });
+    $("#matrix_form").on("submit", function(ev) {
+        ev.preventDefault();
+        nav.store({"matrixRuns": $("#matrix_runs").val()});
+        loadMatrix();
+    });
+    $("#compare_files").on("submit", function(ev) {
+        ev.preventDefault();
+        openComparePage({"compareA": $("#compare_a").val(), "compareB": $("#compare_b").val()});
+    });
+    $("#compare_versions").on("submit", function(ev) {
+        ev.preventDefault();
+        openComparePage({
+            "compareSuite": $("#compare_suite").val(),
+            "versionA": $("#compare_version_a").val(),
+            "versionB": $("#compare_version_b").val(),
+        });
+    });
     window.addEventListener("popstate", navigationDispatch);
     navigationDispatch();
 });
@@ -323,6 +412,12 @@ function navigationDispatch() {
 
         });
     }
+    if (nav.load("q")) {
+        runSearch();
+    }
+    if (nav.load("compareA") || nav.load("compareSuite")) {
+        loadComparison();
+    }
     let page = nav.load("page") || "v-pills-home-tab";
     let elem = $("#" + page);
     if (elem && elem.tab) {
@@ -340,6 +435,200 @@ function openTestSuitePage(suitefile) {
     $("#v-pills-results-tab").tab("show")
 }
 
+// openComparePage navigates to the comparison tab and compares the given runs.
+function openComparePage(params) {
+    let keys = ["compareA", "compareB", "compareSuite", "versionA", "versionB"];
+    let search = new URLSearchParams(location.search);
+    keys.forEach(function(key) { search.delete(key); });
+    search.set("page", "v-pills-compare-tab");
+    for (let key in params) {
+        search.set(key, params[key]);
+    }
+    history.pushState(null, null, "?" + search.toString());
+    $("#v-pills-compare-tab").tab("show");
+    loadComparison();
+}
+
+// fillCompareLists sets the suggestions of the comparison inputs.
+function fillCompareLists(suites) {
+    let files = [], names = {}, clients = {};
+    suites.forEach(function(suite) {
+        files.push(suite.fileName);
+        names[suite.name] = true;
+        suite.clients.forEach(function(client) { clients[client] = true; });
+    });
+    let fill = function(id, values) {
+        let list = $(id).empty();
+        values.forEach(function(v) { list.append($("<option>").attr("value", v)); });
+    };
+    fill("#compare_filelist", files);
+    fill("#compare_suitelist", Object.keys(names).sort());
+    fill("#compare_clientlist", Object.keys(clients).sort());
+}
+
+// loadComparison fetches the comparison selected by the URL.
+function loadComparison() {
+    let query = {};
+    if (nav.load("compareA")) {
+        query = {"a": nav.load("compareA"), "b": nav.load("compareB")};
+        $("#compare_a").val(query.a);
+        $("#compare_b").val(query.b);
+    } else {
+        query = {"suite": nav.load("compareSuite"), "versionA": nav.load("versionA"), "versionB": nav.load("versionB")};
+        $("#compare_suite").val(query.suite);
+        $("#compare_version_a").val(query.versionA);
+        $("#compare_version_b").val(query.versionB);
+    }
+    progress("Loading comparison...");
+    $("#compare_result").text("Loading...");
+    $.getJSON("compare?" + $.param(query), showComparison).fail(function(x, status, err) {
+        progress("error loading comparison: " + x.responseText);
+        $("#compare_result").text("Comparison failed: " + (x.responseText || err));
+    });
+}
+
+// showComparison renders the result of the compare endpoint.
+function showComparison(c) {
+    let runInfo = function(run, label) {
+        let suiteLink = utils.get_link("?page=v-pills-results-tab&suite=" + encodeURIComponent(run.fileName), run.fileName);
+        let html = "<p><b>" + label + ":</b> " + suiteLink + " (" + logview("results/" + run.simLog, "simulator log") + ") ";
+        html += resultStats(run.fails, run.passes, run.fails + run.passes) + "</p>";
+        html += utils.make_definition_list(run.clientVersions || {}).outerHTML;
+        return html;
+    };
+    let testInfo = function(test) {
+        if (!test) {
+            return "<i>missing</i>";
+        }
+        let html = test.pass ? "&#x2713" : "&#x2715; <b>Fail</b>";
+        let logs = test.clients.map(function(client) {
+            return logview("results/" + client.logFile, client.name);
+        });
+        if (logs.length > 0) {
+            html += " " + logs.join(",");
+        }
+        if (test.details) {
+            html += "<details><summary>details</summary><pre><code>" + utils.urls_to_links(utils.html_encode(test.details)) + "</code></pre></details>";
+        }
+        return html;
+    };
+    let diffTable = function(title, list) {
+        let html = "<h4>" + title + " (" + list.length + ")</h4>";
+        if (list.length == 0) {
+            return html;
+        }
+        html += '<table class="table table-sm table-bordered"><thead><tr><th style="width: 40%">Test</th><th>Run A</th><th>Run B</th></tr></thead><tbody>';
+        list.forEach(function(diff) {
+            html += "<tr><td>" + utils.html_encode(diff.name) + "</td><td>" + testInfo(diff.a) + "</td><td>" + testInfo(diff.b) + "</td></tr>";
+        });
+        return html + "</tbody></table>";
+    };
+
+    let html = '<div class="row"><div class="col">' + runInfo(c.a, "Run A") + '</div><div class="col">' + runInfo(c.b, "Run B") + "</div></div>";
+    html += "<p>" + c.unchanged + " tests unchanged, " + c.added + " new passing tests.</p>";
+    html += diffTable("Newly failing", c.newFailures);
+    html += diffTable("Newly passing", c.newPasses);
+    html += diffTable("Removed", c.removed);
+    $("#compare_result").html(html);
+    progress("Comparison loaded");
+}
+
+// runSearch performs the full-text search selected by the URL.
+function runSearch() {
+    let query = nav.load("q");
+    $("#search_query").val(query);
+    $("#search_status").text("Searching...");
+    $("#search_results").empty();
+    $.getJSON("search?" + $.param({"q": query}), function(results) {
+        showSearchResults(query, results);
+    }).fail(function(x, status, err) {
+        $("#search_status").text("Search failed: " + (x.responseText || err));
+    });
+}
+
+// showSearchResults renders the results of the search endpoint.
+function showSearchResults(query, results) {
+    $("#search_status").text(results.length + " results for '" + query + "'.");
+    if (results.length == 0) {
+        return;
+    }
+    let html = '<thead><tr><th style="width: 25%">Suite</th><th style="width: 25%">Test</th><th>Match</th></tr></thead><tbody>';
+    results.forEach(function(r) {
+        let suiteLink = utils.get_link("?page=v-pills-results-tab&suite=" + encodeURIComponent(r.fileName), r.suiteName);
+        let source = r.logFile ? logview("results/" + r.logFile, "client log") : "details";
+        html += "<tr><td>" + suiteLink + "<br/><small>" + utils.html_encode(r.fileName) + "</small></td>";
+        html += "<td>" + utils.html_encode(r.testName) + " <small>(#" + r.testID + ")</small></td>";
+        html += "<td><small>" + source + "</small><pre>" + highlightMatch(r.snippet, query) + "</pre></td></tr>";
+    });
+    $("#search_results").html(html + "</tbody>");
+}
+
+// highlightMatch HTML-encodes text and highlights the first occurrence of query.
+function highlightMatch(text, query) {
+    let pos = text.toLowerCase().indexOf(query.toLowerCase());
+    if (pos < 0) {
+        return utils.html_encode(text);
+    }
+    let end = pos + query.length;
+    return utils.html_encode(text.substring(0, pos)) + "<mark>" + utils.html_encode(text.substring(pos, end)) + "</mark>" + utils.html_encode(text.substring(end));
+}
+
+// loadMatrix fetches and renders the client compatibility matrix.
+function loadMatrix() {
+    let runs = nav.load("matrixRuns") || $("#matrix_runs").val();
+    $("#matrix_runs").val(runs);
+    progress("Loading matrix...");
+    $.getJSON("matrix.json?runs=" + encodeURIComponent(runs), showMatrix).fail(function(x, status, err) {
+        progress("error loading matrix: " + err);
+        $("#matrix").text("Loading the matrix failed: " + err);
+    });
+}
+
+// passRateColor returns the background color for a pass rate.
+function passRateColor(rate) {
+    if (rate >= 1) {
+        return "#c3e6cb";
+    }
+    if (rate >= 0.9) {
+        return "#ffeeba";
+    }
+    return "#f5c6cb";
+}
+
+// showMatrix renders the client compatibility matrix.
+function showMatrix(m) {
+    let html = "<thead><tr><th>Suite</th>";
+    m.clients.forEach(function(client) {
+        html += "<th>" + utils.html_encode(client) + "</th>";
+    });
+    html += "</tr></thead><tbody>";
+    m.suites.forEach(function(row) {
+        html += "<tr><th>" + utils.html_encode(row.name) + "</th>";
+        m.clients.forEach(function(client) {
+            let cell = row.cells[client];
+            if (!cell) {
+                html += "<td></td>";
+                return;
+            }
+            let total = cell.passes + cell.fails;
+            let trend = ["&#x25BC;", "", "&#x25B2;"][cell.trend + 1];
+            html += '<td style="background-color: ' + passRateColor(cell.passRate) + '">';
+            html += '<b title="' + cell.passes + " / " + total + ' tests passed">' + (cell.passRate * 100).toFixed(1) + "%</b> " + trend + "<br/>";
+            cell.runs.forEach(function(run) {
+                let rate = run.passes / Math.max(run.passes + run.fails, 1);
+                let title = new Date(run.start).toISOString() + ": " + run.passes + " / " + (run.passes + run.fails);
+                let href = "?page=v-pills-results-tab&suite=" + encodeURIComponent(run.fileName);
+                html += '<a class="run-box" style="background-color: ' + passRateColor(rate) + '; border: 1px solid #999"';
+                html += ' href="' + utils.attr_encode(href) + '" title="' + utils.attr_encode(title) + '"></a>';
+            });
+            html += "</td>";
+        });
+        html += "</tr>";
+    });
+    $("#matrix").html(html + "</tbody>");
+    progress("Matrix loaded");
+}
+
 // loadTestSuite loads the given testsuite file.
 function loadTestSuite(suitefile, doneFn) {
     //let filename = "results/"+suitefile
@@ -391,6 +680,131 @@ function formatTestDetails(d) {
     return txt;
 }
 
+// buildTestTree links test cases to their parent test and computes the position of each
+// test in the tree. It returns true if any test has subtests.
+function buildTestTree(cases) {
+    let byID = {};
+    cases.forEach(function(testCase) {
+        byID[testCase.testID] = testCase;
+        testCase.children = [];
+    });
+    let roots = [];
+    cases.forEach(function(testCase) {
+        let parent = byID[testCase.parent];
+        testCase.parentCase = parent || null;
+        if (parent) {
+            parent.children.push(testCase);
+        } else {
+            roots.push(testCase);
+        }
+    });
+
+    // Walk the tree in depth-first order.
+    let index = 0;
+    let hasTree = false;
+    let visit = function(testCase, depth) {
+        testCase.depth = depth;
+        testCase.treeIndex = index++;
+        testCase.collapsed = testCase.children.length > 0;
+        testCase.failedSubtests = 0;
+        testCase.children.sort(function(a, b) { return a.testID - b.testID; });
+        testCase.children.forEach(function(child) {
+            hasTree = true;
+            visit(child, depth + 1);
+            testCase.failedSubtests += child.failedSubtests;
+            if (!child.summaryResult.pass) {
+                testCase.failedSubtests++;
+            }
+        });
+    };
+    roots.sort(function(a, b) { return a.testID - b.testID; });
+    roots.forEach(function(testCase) { visit(testCase, 0); });
+    return hasTree;
+}
+
+// formatTreeName renders the test name with indentation and the expand/collapse toggle.
+function formatTreeName(testCase) {
+    let html = '<span style="padding-left: ' + (testCase.depth * 1.5) + 'em"></span>';
+    if (testCase.children.length > 0) {
+        let arrow = testCase.collapsed ? "&#x25B8;" : "&#x25BE;";
+        html += '<a href="javascript:void(0)" class="tree-toggle">' + arrow + '</a>&nbsp;';
+    }
+    html += utils.html_encode(testCase.name);
+    (testCase.tags || []).forEach(function(tag) {
+        html += ' <span class="badge badge-secondary">' + utils.html_encode(tag) + '</span>';
+    });
+    if (testCase.children.length > 0) {
+        let info = testCase.children.length + " subtests";
+        if (testCase.failedSubtests > 0) {
+            info += ", " + testCase.failedSubtests + " failed";
+        }
+        html += ' <small class="text-muted">(' + info + ')</small>';
+    }
+    return html;
+}
+
+// testVisible reports whether all ancestors of a test case are expanded.
+function testVisible(testCase) {
+    for (let p = testCase.parentCase; p; p = p.parentCase) {
+        if (p.collapsed) {
+            return false;
+        }
+    }
+    return true;
+}
+
+// selectedTag is the tag selected in the test results tag filter.
+var selectedTag = null;
+
+// showTagFilter renders the tag filter buttons for the given test cases.
+function showTagFilter(cases) {
+    let counts = {};
+    cases.forEach(function(testCase) {
+        (testCase.tags || []).forEach(function(tag) {
+            counts[tag] = (counts[tag] || 0) + 1;
+        });
+    });
+    let tags = Object.keys(counts).sort();
+    let container = $("#testsuite_tags").empty();
+    selectedTag = null;
+    if (tags.length == 0) {
+        return;
+    }
+
+    container.append("<b>Tags:</b> ");
+    let addButton = function(tag, label) {
+        let btn = $('<button type="button" class="btn btn-sm btn-outline-secondary mr-1"></button>');
+        btn.text(label);
+        btn.toggleClass("active", tag === selectedTag);
+        btn.on("click", function() {
+            selectedTag = tag;
+            container.find("button").removeClass("active");
+            btn.addClass("active");
+            $("#execresults").DataTable().draw();
+        });
+        container.append(btn);
+    };
+    addButton(null, "all");
+    tags.forEach(function(tag) {
+        addButton(tag, tag + " (" + counts[tag] + ")");
+    });
+}
+
+// This hides subtests of collapsed tests and applies the tag filter. When searching or
+// filtering by tag, all matching tests are shown.
+$.fn.dataTable.ext.search.push(function(settings, searchData, index, rowData) {
+    if (settings.nTable.id !== "execresults") {
+        return true;
+    }
+    if (selectedTag !== null) {
+        return (rowData.tags || []).indexOf(selectedTag) >= 0;
+    }
+    if (settings.oPreviousSearch.sSearch) {
+        return true;
+    }
+    return testVisible(rowData);
+});
+
 function onSuiteData(data, jsonsource) {
     // data structure of suite data:
     /*
@@ -444,9 +858,13 @@ function onSuiteData(data, jsonsource) {
     // Convert to list
     let cases = []
     for (var k in data.testCases) {
-        cases.push(data.testCases[k])
+        let testCase = data.testCases[k];
+        testCase.testID = parseInt(k);
+        cases.push(testCase)
     }
     progress("got " + cases.length + " testcases")
+    let hasTree = buildTestTree(cases);
+    showTagFilter(cases);
 
     //datatables can't be reinitalized, we need to destroy them if they exist
     if (execresults != null) {
@@ -459,7 +877,7 @@ function onSuiteData(data, jsonsource) {
         data: cases,
         pageLength: 100,
         autoWidth: false,
-        order: [[2, 'desc']],
+        order: hasTree ? [[4, 'asc']] : [[2, 'desc']],
         columns: [
             // First column is an 'expand'-button
             {
@@ -472,8 +890,14 @@ function onSuiteData(data, jsonsource) {
             // Second column: Name
             {
                 title: "Test",
-                data: "name",
+                data: null,
                 width: "79%",
+                render: function(testCase, type) {
+                    if (type !== "display") {
+                        return testCase.name;
+                    }
+                    return formatTreeName(testCase);
+                },
             },
             //  Status: pass or not
             {
@@ -501,6 +925,12 @@ function onSuiteData(data, jsonsource) {
                 },
                 width: "19%",
             },
+            // Hidden column: position in test tree
+            {
+                data: "treeIndex",
+                visible: false,
+                searchable: false,
+            },
         ],
     });
 
@@ -519,6 +949,13 @@ function onSuiteData(data, jsonsource) {
             tr.addClass('shown');
         }
     });
+
+    // This expands and collapses subtests.
+    $('#execresults tbody').on('click', '.tree-toggle', function() {
+        let testCase = thetable.row($(this).closest('tr')).data();
+        testCase.collapsed = !testCase.collapsed;
+        thetable.draw(false);
+    });
     execresults = thetable
     return
     /*  if(params.execfilter){
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+var errNoMatchingRun = errors.New("no matching run")
+
+// comparison is the result of comparing two runs of a test suite.
+type comparison struct {
+	A comparisonRun `json:"a"`
+	B comparisonRun `json:"b"`
+
+	NewFailures []testDiff `json:"newFailures"` // failing in B, passing or absent in A
+	NewPasses   []testDiff `json:"newPasses"`   // passing in B, failing in A
+	Removed     []testDiff `json:"removed"`     // present in A, absent in B
+	Added       int        `json:"added"`       // number of new passing tests in B
+	Unchanged   int        `json:"unchanged"`   // number of tests with the same result
+}
+
+// comparisonRun describes one side of a comparison.
+type comparisonRun struct {
+	FileName       string            `json:"fileName"`
+	Name           string            `json:"name"`
+	SimLog         string            `json:"simLog"`
+	ClientVersions map[string]string `json:"clientVersions"`
+	Passes         int               `json:"passes"`
+	Fails          int               `json:"fails"`
+}
+
+// testDiff is a test whose result differs between the two runs.
+type testDiff struct {
+	Name string        `json:"name"`
+	A    *comparedTest `json:"a,omitempty"`
+	B    *comparedTest `json:"b,omitempty"`
+}
+
+// comparedTest is the result of a test in one of the compared runs.
+type comparedTest struct {
+	ID      libhive.TestID        `json:"id"`
+	Pass    bool                  `json:"pass"`
+	Details string                `json:"details"`
+	Clients []*libhive.ClientInfo `json:"clients"`
+}
+
+// loadComparison loads two suite files from fsys and compares them.
+func loadComparison(fsys fs.FS, fileA, fileB string) (*comparison, error) {
+	a, err := loadSuiteFile(fsys, fileA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := loadSuiteFile(fsys, fileB)
+	if err != nil {
+		return nil, err
+	}
+	return compareSuites(a, fileA, b, fileB), nil
+}
+
+// maxComparisonScan is the number of summary files searched by loadVersionComparison.
+const maxComparisonScan = 1000
+
+// loadVersionComparison compares the latest runs of a suite for two client versions.
+// The versions are matched against both client names and version strings in the
+// ClientVersions of each run. Each side of the comparison is a different run: when a
+// run matches both versions, it is used for A, and B is the latest other matching run.
+// Only the newest maxComparisonScan summary files are searched.
+func loadVersionComparison(fsys fs.FS, suiteName, versionA, versionB string) (*comparison, error) {
+	var (
+		stop         = errors.New("stop")
+		a, b         *libhive.TestSuite
+		fileA, fileB string
+		scanned      int
+	)
+	// Files are walked newest-first, so the first match is the latest run.
+	err := walkSummaryFiles(fsys, ".", func(suite *libhive.TestSuite, fi fs.FileInfo) error {
+		if scanned++; scanned > maxComparisonScan {
+			return stop
+		}
+		if suite.Name != suiteName {
+			return nil
+		}
+		switch {
+		case a == nil && hasClientVersion(suite, versionA):
+			a, fileA = suite, fi.Name()
+		case b == nil && hasClientVersion(suite, versionB):
+			b, fileB = suite, fi.Name()
+		}
+		if a != nil && b != nil {
+			return stop
+		}
+		return nil
+	})
+	if err != nil && err != stop {
+		return nil, err
+	}
+	if a == nil {
+		return nil, fmt.Errorf("%w: suite %q with client %q", errNoMatchingRun, suiteName, versionA)
+	}
+	if b == nil {
+		return nil, fmt.Errorf("%w: suite %q with client %q", errNoMatchingRun, suiteName, versionB)
+	}
+	return compareSuites(a, fileA, b, fileB), nil
+}
+
+func loadSuiteFile(fsys fs.FS, file string) (*libhive.TestSuite, error) {
+	if !fs.ValidPath(file) || strings.Contains(file, "/") || skipFile(file) {
+		return nil, fmt.Errorf("invalid suite file name %q", file)
+	}
+	suite, _ := parseSuite(fsys, file)
+	if suite == nil {
+		return nil, fmt.Errorf("%w: can't load suite file %q", errNoMatchingRun, file)
+	}
+	return suite, nil
+}
+
+func hasClientVersion(suite *libhive.TestSuite, version string) bool {
+	for name, v := range suite.ClientVersions {
+		if name == version || v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// compareSuites computes the differences between two runs. Tests are matched by their
+// name, including the names of their parent tests.
+func compareSuites(a *libhive.TestSuite, fileA string, b *libhive.TestSuite, fileB string) *comparison {
+	c := &comparison{
+		A:           newComparisonRun(a, fileA),
+		B:           newComparisonRun(b, fileB),
+		NewFailures: []testDiff{},
+		NewPasses:   []testDiff{},
+		Removed:     []testDiff{},
+	}
+	testsA := testsByPath(a)
+	testsB := testsByPath(b)
+
+	for name, tb := range testsB {
+		ta := testsA[name]
+		switch {
+		case ta != nil && ta.Pass == tb.Pass:
+			c.Unchanged++
+		case !tb.Pass:
+			c.NewFailures = append(c.NewFailures, testDiff{Name: name, A: ta, B: tb})
+		case ta != nil:
+			c.NewPasses = append(c.NewPasses, testDiff{Name: name, A: ta, B: tb})
+		default:
+			c.Added++
+		}
+	}
+	for name, ta := range testsA {
+		if testsB[name] == nil {
+			c.Removed = append(c.Removed, testDiff{Name: name, A: ta})
+		}
+	}
+
+	for _, list := range [][]testDiff{c.NewFailures, c.NewPasses, c.Removed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	return c
+}
+
+func newComparisonRun(s *libhive.TestSuite, file string) comparisonRun {
+	run := comparisonRun{
+		FileName:       file,
+		Name:           s.Name,
+		SimLog:         s.SimulatorLog,
+		ClientVersions: s.ClientVersions,
+	}
+	for _, test := range s.TestCases {
+		if test.SummaryResult.Pass {
+			run.Passes++
+		} else {
+			run.Fails++
+		}
+	}
+	return run
+}
+
+// testsByPath returns the tests of a suite keyed by their path in the test tree.
+// Tests with the same path are numbered in the order of their IDs.
+func testsByPath(s *libhive.TestSuite) map[string]*comparedTest {
+	ids := make([]libhive.TestID, 0, len(s.TestCases))
+	for id := range s.TestCases {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tests := make(map[string]*comparedTest, len(ids))
+	for _, id := range ids {
+		test := s.TestCases[id]
+		name := testPath(s, test)
+		for n := 2; tests[name] != nil; n++ {
+			name = fmt.Sprintf("%s #%d", testPath(s, test), n)
+		}
+		ct := &comparedTest{
+			ID:      id,
+			Pass:    test.SummaryResult.Pass,
+			Details: test.SummaryResult.Details,
+			Clients: make([]*libhive.ClientInfo, 0, len(test.ClientInfo)),
+		}
+		for _, client := range test.ClientInfo {
+			ct.Clients = append(ct.Clients, client)
+		}
+		sort.Slice(ct.Clients, func(i, j int) bool { return ct.Clients[i].Name < ct.Clients[j].Name })
+		tests[name] = ct
+	}
+	return tests
+}
+
+// testPath returns the name of a test, prefixed by the names of its parent tests.
+func testPath(s *libhive.TestSuite, test *libhive.TestCase) string {
+	name := test.Name
+	for depth := 0; test.Parent != 0 && depth < len(s.TestCases); depth++ {
+		parent := s.TestCases[test.Parent]
+		if parent == nil {
+			break
+		}
+		name = parent.Name + "/" + name
+		test = parent
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+func testSuite(name string, versions map[string]string, tests ...*libhive.TestCase) *libhive.TestSuite {
+	s := &libhive.TestSuite{
+		Name:           name,
+		ClientVersions: versions,
+		SimulatorLog:   "sim.log",
+		TestCases:      make(map[libhive.TestID]*libhive.TestCase),
+	}
+	for i, test := range tests {
+		s.TestCases[libhive.TestID(i+1)] = test
+	}
+	return s
+}
+
+func testCase(name string, parent libhive.TestID, pass bool) *libhive.TestCase {
+	return &libhive.TestCase{Name: name, Parent: parent, SummaryResult: libhive.TestResult{Pass: pass}}
+}
+
+func diffNames(list []testDiff) []string {
+	names := []string{}
+	for _, d := range list {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestCompareSuites(t *testing.T) {
+	a := testSuite("suite", nil,
+		testCase("parent", 0, true),          // 1
+		testCase("sub", 1, true),             // 2
+		testCase("unchanged-fail", 0, false), // 3
+		testCase("fixed", 0, false),          // 4
+		testCase("removed", 0, true),         // 5
+		testCase("dup", 0, true),             // 6
+		testCase("dup", 0, true),             // 7
+	)
+	b := testSuite("suite", nil,
+		testCase("parent", 0, true),          // 1
+		testCase("sub", 1, false),            // 2
+		testCase("unchanged-fail", 0, false), // 3
+		testCase("fixed", 0, true),           // 4
+		testCase("added", 0, true),           // 5
+		testCase("added-fail", 0, false),     // 6
+		testCase("dup", 0, true),             // 7
+		testCase("dup", 0, false),            // 8
+	)
+	c := compareSuites(a, "a.json", b, "b.json")
+
+	if want := []string{"added-fail", "dup #2", "parent/sub"}; !reflect.DeepEqual(diffNames(c.NewFailures), want) {
+		t.Errorf("wrong new failures %v, want %v", diffNames(c.NewFailures), want)
+	}
+	if want := []string{"fixed"}; !reflect.DeepEqual(diffNames(c.NewPasses), want) {
+		t.Errorf("wrong new passes %v, want %v", diffNames(c.NewPasses), want)
+	}
+	if want := []string{"removed"}; !reflect.DeepEqual(diffNames(c.Removed), want) {
+		t.Errorf("wrong removed tests %v, want %v", diffNames(c.Removed), want)
+	}
+	if c.Added != 1 {
+		t.Errorf("wrong added count %d, want 1", c.Added)
+	}
+	if c.Unchanged != 3 {
+		t.Errorf("wrong unchanged count %d, want 3", c.Unchanged)
+	}
+	if c.NewFailures[0].A != nil || c.NewFailures[1].A == nil {
+		t.Error("wrong A side of new failures")
+	}
+	if c.A.FileName != "a.json" || c.A.Passes != 5 || c.A.Fails != 2 {
+		t.Errorf("wrong run A: %+v", c.A)
+	}
+	if c.B.FileName != "b.json" || c.B.Passes != 4 || c.B.Fails != 4 {
+		t.Errorf("wrong run B: %+v", c.B)
+	}
+}
+
+func suiteFile(t *testing.T, s *libhive.TestSuite) *fstest.MapFile {
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fstest.MapFile{Data: data}
+}
+
+func TestLoadVersionComparison(t *testing.T) {
+	both := map[string]string{"geth_a": "geth 1.0", "geth_b": "geth 1.1"}
+	fsys := fstest.MapFS{
+		"1-a.json":     suiteFile(t, testSuite("suite", map[string]string{"geth_a": "geth 1.0"})),
+		"2-b.json":     suiteFile(t, testSuite("suite", map[string]string{"geth_b": "geth 1.1"})),
+		"3-both.json":  suiteFile(t, testSuite("suite", both)),
+		"4-other.json": suiteFile(t, testSuite("other", both)),
+	}
+	tests := []struct {
+		versionA, versionB string
+		wantA, wantB       string
+	}{
+		{"geth_a", "geth_b", "3-both.json", "2-b.json"},
+		{"geth_b", "geth_a", "3-both.json", "1-a.json"},
+		{"geth 1.0", "geth_a", "3-both.json", "1-a.json"},
+		{"geth_b", "geth_b", "3-both.json", "2-b.json"},
+	}
+	for _, test := range tests {
+		c, err := loadVersionComparison(fsys, "suite", test.versionA, test.versionB)
+		if err != nil {
+			t.Errorf("%s vs. %s: %v", test.versionA, test.versionB, err)
+			continue
+		}
+		if c.A.FileName != test.wantA || c.B.FileName != test.wantB {
+			t.Errorf("%s vs. %s: compared %s and %s, want %s and %s", test.versionA, test.versionB, c.A.FileName, c.B.FileName, test.wantA, test.wantB)
+		}
+	}
+
+	// A run matching both versions isn't compared with itself.
+	if _, err := loadVersionComparison(fsys, "other", "geth_a", "geth_b"); !errors.Is(err, errNoMatchingRun) {
+		t.Errorf("wrong error for single run: %v", err)
+	}
+}
+
+func TestLoadVersionComparisonLimit(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0-a.json": suiteFile(t, testSuite("suite", map[string]string{"geth_a": ""})),
+	}
+	for i := 1; i <= maxComparisonScan; i++ {
+		fsys[fmt.Sprintf("%05d.json", i)] = suiteFile(t, testSuite("suite", map[string]string{"geth_b": ""}))
+	}
+	if _, err := loadVersionComparison(fsys, "suite", "geth_a", "geth_b"); !errors.Is(err, errNoMatchingRun) {
+		t.Errorf("run beyond the scan limit was found, err: %v", err)
+	}
+}
@@ -3,73 +3,299 @@ package main
 import (
 	"fmt"
 	"io/fs"
-	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/logstore"
 )
 
-func logdirGC(dir string, cutoff time.Time, keepMin int) error {
-	var (
-		fsys       = os.DirFS(dir)
-		usedFiles  = make(map[string]struct{})
-		keptSuites = 0
-		oldest     time.Time
-	)
+// gcPolicy configures which suite runs are kept by logdirGC. A suite is kept when any of
+// the rules select it.
+type gcPolicy struct {
+	cutoff        time.Time // suites started after this time are kept
+	keepMin       int       // number of newest suites which are always kept
+	keepPerClient int       // number of latest runs kept per suite+client combination
+	keepFailures  bool      // keep runs which introduced a test failure
+	maxSize       int64     // size budget of the log directory in bytes, zero means no limit
+	dryRun        bool      // only print what would be deleted
+}
+
+// gcSuite is a suite file considered for deletion.
+type gcSuite struct {
+	file    string
+	name    string
+	start   time.Time
+	files   []string            // suite file, logs and other result files
+	results map[string]gcResult // test results by client name
 
-	// Walk all suite files and pouplate the usedFiles set.
-	err := walkSummaryFiles(fsys, ".", func(suite *libhive.TestSuite, fi fs.FileInfo) error {
-		// Skip when too old and when above the minimum.
-		// Note we rely on getting called in descending time order here.
-		if suiteStart(suite).Before(cutoff) && keptSuites >= keepMin {
+	keep      bool
+	protected bool // kept even when the size budget is exceeded
+}
+
+// gcResult contains the test results of a suite involving a client.
+type gcResult struct {
+	passed map[string]bool // by test path
+	failed map[string]bool
+}
+
+func logdirGC(fsys logstore.Store, policy gcPolicy) error {
+	// Collect all files and their sizes.
+	sizes := make(map[string]int64)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Ignore scan errors.
+		}
+		if d.IsDir() {
+			if path != "." && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir // Don't touch hidden directories, e.g. the listing index.
+			}
 			return nil
 		}
-		if oldest.IsZero() || suiteStart(suite).Before(oldest) {
-			oldest = suiteStart(suite)
+		if info, err := d.Info(); err == nil {
+			sizes[path] = info.Size()
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// Add suite files and client logs.
-		keptSuites++
-		usedFiles[fi.Name()] = struct{}{}
-		usedFiles[suite.SimulatorLog] = struct{}{}
-		for _, test := range suite.TestCases {
-			for _, client := range test.ClientInfo {
-				usedFiles[client.LogFile] = struct{}{}
-			}
-		}
+	// Load suites.
+	var suites []*gcSuite
+	err = walkSummaryFiles(fsys, ".", func(suite *libhive.TestSuite, fi fs.FileInfo) error {
+		suites = append(suites, newGCSuite(suite, fi.Name()))
 		return nil
 	})
 	if err != nil {
 		return err
 	}
+	sort.SliceStable(suites, func(i, j int) bool {
+		if !suites[i].start.Equal(suites[j].start) {
+			return suites[i].start.After(suites[j].start)
+		}
+		return suites[i].file > suites[j].file
+	})
+	policy.apply(suites)
+
+	// Count references to files of kept suites.
+	var (
+		refs       = make(map[string]int)
+		keptSize   int64
+		keptSuites int
+		oldest     time.Time
+	)
+	for _, s := range suites {
+		if s.keep {
+			keptSize += s.addRefs(refs, sizes, 1)
+		}
+	}
+	// Enforce the size budget by removing the oldest suites first.
+	if policy.maxSize > 0 {
+		for i := len(suites) - 1; i >= 0 && keptSize > policy.maxSize; i-- {
+			if s := suites[i]; s.keep && !s.protected {
+				s.keep = false
+				keptSize += s.addRefs(refs, sizes, -1)
+			}
+		}
+		if keptSize > policy.maxSize {
+			fmt.Printf("warning: kept suites use %s, exceeding the size budget of %s\n", formatBytes(keptSize), formatBytes(policy.maxSize))
+		}
+	}
+	for _, s := range suites {
+		if s.keep {
+			keptSuites++
+			if oldest.IsZero() || s.start.Before(oldest) {
+				oldest = s.start
+			}
+		}
+	}
 
-	fmt.Printf("keeping %d suites (%d files)\n", keptSuites, len(usedFiles))
+	fmt.Printf("keeping %d of %d suites (%d files, %s)\n", keptSuites, len(suites), len(refs), formatBytes(keptSize))
 	fmt.Println("oldest suite date:", oldest)
 
-	// Delete all files which aren't in usedFiles.
-	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Ignore scan errors.
+	// Delete all files which aren't referenced by kept suites.
+	paths := make([]string, 0, len(sizes))
+	for path := range sizes {
+		if refs[path] == 0 {
+			paths = append(paths, path)
 		}
-		if d.IsDir() {
-			return nil // Don't delete directories.
-		}
-		if _, used := usedFiles[path]; !used {
-			file := filepath.Join(dir, filepath.FromSlash(path))
-			// fmt.Println("rm", file)
-			err := os.Remove(file)
-			if err != nil {
-				fmt.Println("error:", err)
+	}
+	sort.Strings(paths)
+	var (
+		deleted   int
+		reclaimed int64
+	)
+	for _, path := range paths {
+		if policy.dryRun {
+			fmt.Println("rm", path)
+		} else if err := fsys.Remove(path); err != nil {
+			fmt.Println("error:", err)
+			continue
+		}
+		deleted++
+		reclaimed += sizes[path]
+	}
+	if policy.dryRun {
+		fmt.Printf("would delete %d files, reclaiming %s\n", deleted, formatBytes(reclaimed))
+	} else {
+		fmt.Printf("deleted %d files, reclaimed %s\n", deleted, formatBytes(reclaimed))
+	}
+	return nil
+}
+
+func newGCSuite(suite *libhive.TestSuite, file string) *gcSuite {
+	s := &gcSuite{
+		file:    file,
+		name:    suite.Name,
+		start:   suiteStart(suite),
+		files:   []string{file},
+		results: make(map[string]gcResult),
+	}
+	if suite.SimulatorLog != "" {
+		s.files = append(s.files, suite.SimulatorLog)
+	}
+	if suite.RunConfig != "" {
+		s.files = append(s.files, suite.RunConfig)
+	}
+	s.files = append(s.files, libhive.JUnitFileName(file))
+	for _, test := range suite.TestCases {
+		// Tests without clients are attributed to the empty client name.
+		clients := []string{""}
+		if len(test.ClientInfo) > 0 {
+			clients = clients[:0]
+		}
+		for _, client := range test.ClientInfo {
+			if client.LogFile != "" {
+				s.files = append(s.files, client.LogFile)
+			}
+			if !contains(clients, client.Name) {
+				clients = append(clients, client.Name)
 			}
 		}
-		return nil
-	})
+		name := testPath(suite, test)
+		for _, client := range clients {
+			r, ok := s.results[client]
+			if !ok {
+				r = gcResult{passed: make(map[string]bool), failed: make(map[string]bool)}
+				s.results[client] = r
+			}
+			if test.SummaryResult.Pass {
+				r.passed[name] = true
+			} else {
+				r.failed[name] = true
+			}
+		}
+	}
+	return s
+}
+
+// addRefs adds delta to the reference counts of the suite's files. It returns the
+// change of the total size of referenced files.
+func (s *gcSuite) addRefs(refs map[string]int, sizes map[string]int64, delta int) int64 {
+	var change int64
+	for _, file := range s.files {
+		before := refs[file]
+		refs[file] += delta
+		switch {
+		case before == 0 && refs[file] > 0:
+			change += sizes[file]
+		case before > 0 && refs[file] == 0:
+			change -= sizes[file]
+			delete(refs, file)
+		}
+	}
+	return change
+}
+
+// apply marks the suites which are kept. The suites must be sorted newest-first.
+func (p *gcPolicy) apply(suites []*gcSuite) {
+	runs := make(map[string]int) // number of runs seen per suite+client
+	for i, s := range suites {
+		if i < p.keepMin {
+			s.keep, s.protected = true, true
+		}
+		if !s.start.Before(p.cutoff) {
+			s.keep = true
+		}
+		if p.keepPerClient > 0 {
+			for client := range s.results {
+				key := s.name + "\x00" + client
+				if runs[key] < p.keepPerClient {
+					s.keep, s.protected = true, true
+				}
+				runs[key]++
+			}
+		}
+	}
+
+	if p.keepFailures {
+		// Walk suites oldest-first and compare each run with the previous
+		// run of the same suite and client.
+		prev := make(map[string]gcResult)
+		for i := len(suites) - 1; i >= 0; i-- {
+			s := suites[i]
+			for client, r := range s.results {
+				key := s.name + "\x00" + client
+				if last, ok := prev[key]; ok && introducesFailure(last, r) {
+					s.keep, s.protected = true, true
+				}
+				prev[key] = r
+			}
+		}
+	}
+}
+
+// introducesFailure reports whether a test which passed in the previous run fails in the
+// current run.
+func introducesFailure(prev, cur gcResult) bool {
+	for name := range cur.failed {
+		if prev.passed[name] {
+			return true
+		}
+	}
+	return false
 }
 
+// suiteStart returns the start time of the earliest test in the suite.
 func suiteStart(suite *libhive.TestSuite) time.Time {
+	var start time.Time
 	for _, test := range suite.TestCases {
-		return test.Start
+		if start.IsZero() || test.Start.Before(start) {
+			start = test.Start
+		}
+	}
+	return start
+}
+
+// parseByteSize parses a size like "500M" or "20GB". Suffixes are powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	str := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	mult := int64(1)
+	if n := len(str); n > 0 {
+		if i := strings.IndexByte("KMGT", str[n-1]); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			str = str[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
 	}
-	return time.Time{}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/hive/internal/logstore"
+)
+
+var gcTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// gcRun describes a suite run in the GC tests.
+type gcRun struct {
+	file, suite, client string
+	hour                int
+	passed, failed      []string
+}
+
+func (r gcRun) gcSuite() *gcSuite {
+	res := gcResult{passed: make(map[string]bool), failed: make(map[string]bool)}
+	for _, name := range r.passed {
+		res.passed[name] = true
+	}
+	for _, name := range r.failed {
+		res.failed[name] = true
+	}
+	return &gcSuite{
+		file:    r.file,
+		name:    r.suite,
+		start:   gcTime.Add(time.Duration(r.hour) * time.Hour),
+		results: map[string]gcResult{r.client: res},
+	}
+}
+
+func TestGCPolicyApply(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        gcPolicy
+		runs          []gcRun // newest-first
+		wantKept      []string
+		wantProtected []string
+	}{
+		{
+			name:   "keepMin",
+			policy: gcPolicy{cutoff: gcTime.Add(100 * time.Hour), keepMin: 2},
+			runs: []gcRun{
+				{file: "3", suite: "s", client: "geth", hour: 3},
+				{file: "2", suite: "s", client: "geth", hour: 2},
+				{file: "1", suite: "s", client: "geth", hour: 1},
+			},
+			wantKept:      []string{"2", "3"},
+			wantProtected: []string{"2", "3"},
+		},
+		{
+			name:   "cutoff",
+			policy: gcPolicy{cutoff: gcTime.Add(2 * time.Hour)},
+			runs: []gcRun{
+				{file: "3", suite: "s", client: "geth", hour: 3},
+				{file: "2", suite: "s", client: "geth", hour: 2},
+				{file: "1", suite: "s", client: "geth", hour: 1},
+			},
+			wantKept: []string{"2", "3"},
+		},
+		{
+			name:   "keepPerClient",
+			policy: gcPolicy{cutoff: gcTime.Add(100 * time.Hour), keepPerClient: 1},
+			runs: []gcRun{
+				{file: "5", suite: "s", client: "geth", hour: 5},
+				{file: "4", suite: "other", client: "geth", hour: 4},
+				{file: "3", suite: "s", client: "besu", hour: 3},
+				{file: "2", suite: "s", client: "geth", hour: 2},
+				{file: "1", suite: "s", client: "besu", hour: 1},
+			},
+			wantKept:      []string{"3", "4", "5"},
+			wantProtected: []string{"3", "4", "5"},
+		},
+		{
+			name:   "keepFailures",
+			policy: gcPolicy{cutoff: gcTime.Add(100 * time.Hour), keepFailures: true},
+			runs: []gcRun{
+				{file: "6", suite: "s", client: "besu", hour: 6, failed: []string{"a"}},
+				{file: "5", suite: "s", client: "geth", hour: 5, passed: []string{"a", "b"}},
+				{file: "4", suite: "s", client: "geth", hour: 4, passed: []string{"a"}, failed: []string{"b"}},
+				{file: "3", suite: "s", client: "geth", hour: 3, failed: []string{"a", "b"}},
+				{file: "2", suite: "s", client: "geth", hour: 2, passed: []string{"b"}, failed: []string{"a"}},
+				{file: "1", suite: "s", client: "geth", hour: 1, passed: []string{"a"}},
+			},
+			// Run 2 breaks test a, run 3 breaks test b. Run 4 fails b, which already
+			// failed before. Run 6 is the first besu run.
+			wantKept:      []string{"2", "3"},
+			wantProtected: []string{"2", "3"},
+		},
+	}
+	for _, test := range tests {
+		var suites []*gcSuite
+		for _, run := range test.runs {
+			suites = append(suites, run.gcSuite())
+		}
+		test.policy.apply(suites)
+
+		var kept, protected []string
+		for _, s := range suites {
+			if s.keep {
+				kept = append(kept, s.file)
+			}
+			if s.protected {
+				protected = append(protected, s.file)
+			}
+		}
+		sort.Strings(kept)
+		sort.Strings(protected)
+		if !reflect.DeepEqual(kept, test.wantKept) {
+			t.Errorf("%s: kept %v, want %v", test.name, kept, test.wantKept)
+		}
+		if !reflect.DeepEqual(protected, test.wantProtected) {
+			t.Errorf("%s: protected %v, want %v", test.name, protected, test.wantProtected)
+		}
+	}
+}
+
+// writeGCSuites creates suite files with a simulator log of logSize bytes each. The
+// suites are started one hour apart, in the order given.
+func writeGCSuites(t *testing.T, dir string, logSize int, names ...string) {
+	for i, name := range names {
+		start := gcTime.Add(time.Duration(i) * time.Hour)
+		suite := testSuite("suite", nil, clientTest("t", true, start, "geth"))
+		suite.SimulatorLog = name + ".log"
+		data, err := json.Marshal(suite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, suite.SimulatorLog), make([]byte, logSize), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func dirFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestLogdirGC(t *testing.T) {
+	all := []string{"1.json", "1.log", "2.json", "2.log", "3.json", "3.log"}
+	tests := []struct {
+		name   string
+		policy gcPolicy
+		want   []string
+	}{
+		{
+			name:   "cutoff",
+			policy: gcPolicy{cutoff: gcTime.Add(time.Hour)},
+			want:   []string{"2.json", "2.log", "3.json", "3.log"},
+		},
+		{
+			name:   "dryRun",
+			policy: gcPolicy{cutoff: gcTime.Add(100 * time.Hour), dryRun: true},
+			want:   all,
+		},
+		{
+			name:   "maxSize",
+			policy: gcPolicy{maxSize: 3000},
+			want:   []string{"2.json", "2.log", "3.json", "3.log"},
+		},
+		{
+			name:   "maxSize-protected",
+			policy: gcPolicy{maxSize: 100, keepMin: 2},
+			want:   []string{"2.json", "2.log", "3.json", "3.log"},
+		},
+		{
+			name:   "maxSize-dryRun",
+			policy: gcPolicy{maxSize: 100, dryRun: true},
+			want:   all,
+		},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		writeGCSuites(t, dir, 1000, "1", "2", "3")
+		if err := logdirGC(logstore.NewDir(dir), test.policy); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if files := dirFiles(t, dir); !reflect.DeepEqual(files, test.want) {
+			t.Errorf("%s: remaining files %s, want %s", test.name, strings.Join(files, " "), strings.Join(test.want, " "))
+		}
+	}
+}
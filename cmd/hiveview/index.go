@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// indexScanInterval is the time between two scans of the log directory.
+const indexScanInterval = 10 * time.Second
+
+var (
+	indexFilePrefix  = []byte("file:")
+	indexVersionKey  = []byte("version")
+	indexVersion     = "2"
+	indexBatchLength = 4096 // max number of operations per write
+)
+
+// listingIndex is a persistent index of suite files. It stores the listing entry of each
+// file along with its modification time and size, so suite files are only parsed when
+// they are new or have changed.
+type listingIndex struct {
+	fsys      fs.FS
+	dir       string
+	db        *leveldb.DB
+	indexLogs bool // whether client logs are indexed for search
+
+	searchReadLimit int64 // max bytes of client logs read per search query
+
+	mu     sync.Mutex
+	files  map[string]*indexRecord // in-memory copy of the index
+	sorted []*listingEntry         // entries of files, newest-first
+
+	started bool
+	closing chan struct{}
+	done    chan struct{} // closed when the update loop has exited
+}
+
+// indexRecord is the index entry of a suite file.
+type indexRecord struct {
+	ModTime time.Time     `json:"modTime"`
+	Size    int64         `json:"size"`
+	Entry   *listingEntry `json:"entry,omitempty"` // nil for invalid suite files
+}
+
+// listingQuery selects entries from the index.
+type listingQuery struct {
+	Suite  string    // case-insensitive substring of the suite name
+	Client string    // client name
+	From   time.Time // earliest start time
+	To     time.Time // latest start time
+	Status string    // "pass" or "fail"
+	Offset int
+	Limit  int
+}
+
+// openListingIndex opens the index database at dbPath, which indexes the suite files
+// in dir of fsys. If indexLogs is true, client log files are indexed for search.
+func openListingIndex(fsys fs.FS, dir, dbPath string, indexLogs bool) (*listingIndex, error) {
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if lerrors.IsCorrupted(err) {
+		log.Printf("Listing index is corrupted, recovering: %v", err)
+		db, err = leveldb.RecoverFile(dbPath, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &listingIndex{
+		fsys:      fsys,
+		dir:       dir,
+		db:        db,
+		indexLogs: indexLogs,
+		files:     make(map[string]*indexRecord),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+
+		searchReadLimit: searchMaxQueryRead,
+	}
+
+	// Rebuild the index when it was created by a different version or configuration.
+	version := indexVersion
+	if indexLogs {
+		version += "+logs"
+	}
+	if v, _ := db.Get(indexVersionKey, nil); string(v) != version {
+		log.Printf("Rebuilding listing index")
+		if err := idx.reset(version); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	it := db.NewIterator(util.BytesPrefix(indexFilePrefix), nil)
+	defer it.Release()
+	for it.Next() {
+		var rec indexRecord
+		if err := json.Unmarshal(it.Value(), &rec); err != nil {
+			continue // will be re-indexed.
+		}
+		name := string(it.Key()[len(indexFilePrefix):])
+		idx.files[name] = &rec
+	}
+	idx.sortEntries()
+	log.Printf("Loaded listing index with %d files", len(idx.files))
+	return idx, it.Error()
+}
+
+// reset deletes all index entries.
+func (idx *listingIndex) reset(version string) error {
+	batch := new(leveldb.Batch)
+	it := idx.db.NewIterator(nil, nil)
+	for it.Next() {
+		batch.Delete(append([]byte{}, it.Key()...))
+		if batch.Len() >= indexBatchLength {
+			if err := idx.db.Write(batch, nil); err != nil {
+				it.Release()
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	it.Release()
+	batch.Put(indexVersionKey, []byte(version))
+	return idx.db.Write(batch, nil)
+}
+
+// start launches the background update loop.
+func (idx *listingIndex) start() {
+	idx.started = true
+	go idx.run()
+}
+
+// Close stops the update loop and closes the index database.
+func (idx *listingIndex) Close() error {
+	close(idx.closing)
+	if idx.started {
+		<-idx.done
+	}
+	return idx.db.Close()
+}
+
+// run updates the index in the background until Close is called. The log directory is
+// scanned every indexScanInterval.
+func (idx *listingIndex) run() {
+	defer close(idx.done)
+	for {
+		if err := idx.update(); err != nil {
+			log.Printf("Can't update listing index: %v", err)
+		}
+		select {
+		case <-time.After(indexScanInterval):
+		case <-idx.closing:
+			return
+		}
+	}
+}
+
+// update scans the log directory and indexes new and modified suite files. Updates
+// are published to queries as they are written, so the listing fills up while a large
+// log directory is indexed for the first time.
+//
+// Only one update may run at a time. The in-memory copy of the index is only modified
+// by update, which allows reading it without holding mu here.
+func (idx *listingIndex) update() error {
+	entries, err := fs.ReadDir(idx.fsys, idx.dir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch   = new(leveldb.Batch)
+		pending = make(map[string]*indexRecord)
+		present = make(map[string]struct{}, len(entries))
+		added   int
+	)
+	for _, entry := range entries {
+		select {
+		case <-idx.closing:
+			return nil
+		default:
+		}
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || skipFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		present[name] = struct{}{}
+		if rec := idx.files[name]; rec != nil && rec.ModTime.Equal(info.ModTime()) && rec.Size == info.Size() {
+			continue
+		}
+
+		rec := &indexRecord{ModTime: info.ModTime(), Size: info.Size()}
+		if idx.files[name] != nil {
+			idx.deleteSuiteText(batch, name)
+		}
+		if suite, fi := parseSuite(idx.fsys, path.Join(idx.dir, name)); suite != nil {
+			entry := suiteToEntry(suite, fi)
+			rec.Entry = &entry
+			idx.indexSuiteText(batch, name, suite)
+		}
+		enc, _ := json.Marshal(rec)
+		batch.Put(indexKey(name), enc)
+		pending[name] = rec
+		added++
+
+		if batch.Len() >= indexBatchLength {
+			if err := idx.db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+			idx.publish(pending, nil)
+			pending = make(map[string]*indexRecord)
+		}
+	}
+	var deleted []string
+	for name := range idx.files {
+		if _, ok := present[name]; !ok {
+			idx.deleteSuiteText(batch, name)
+			batch.Delete(indexKey(name))
+			deleted = append(deleted, name)
+		}
+	}
+	if err := idx.db.Write(batch, nil); err != nil {
+		return err
+	}
+	if len(pending) > 0 || len(deleted) > 0 {
+		idx.publish(pending, deleted)
+	}
+	if added > 0 || len(deleted) > 0 {
+		log.Printf("Listing index updated: %d files indexed, %d total", added, len(idx.files))
+	}
+	return nil
+}
+
+// publish applies written index records to the in-memory copy of the index.
+func (idx *listingIndex) publish(records map[string]*indexRecord, deleted []string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for name, rec := range records {
+		idx.files[name] = rec
+	}
+	for _, name := range deleted {
+		delete(idx.files, name)
+	}
+	idx.sortEntries()
+}
+
+func indexKey(name string) []byte {
+	return append(append([]byte{}, indexFilePrefix...), name...)
+}
+
+// sortEntries updates the sorted list of entries. It must be called with mu held.
+func (idx *listingIndex) sortEntries() {
+	idx.sorted = idx.sorted[:0]
+	for _, rec := range idx.files {
+		if rec.Entry != nil {
+			idx.sorted = append(idx.sorted, rec.Entry)
+		}
+	}
+	sort.Slice(idx.sorted, func(i, j int) bool {
+		a, b := idx.sorted[i], idx.sorted[j]
+		if a.SimLog != b.SimLog {
+			return a.SimLog > b.SimLog
+		}
+		return a.FileName > b.FileName
+	})
+}
+
+// query returns the entries matching q, newest-first, and the total number of matches.
+func (idx *listingIndex) query(q listingQuery) ([]listingEntry, int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var (
+		matches []listingEntry
+		total   int
+	)
+	for _, e := range idx.sorted {
+		if !q.match(e) {
+			continue
+		}
+		if total >= q.Offset && (q.Limit <= 0 || len(matches) < q.Limit) {
+			matches = append(matches, *e)
+		}
+		total++
+	}
+	return matches, total
+}
+
+func (q *listingQuery) match(e *listingEntry) bool {
+	if q.Suite != "" && !strings.Contains(strings.ToLower(e.Name), strings.ToLower(q.Suite)) {
+		return false
+	}
+	if q.Client != "" && !contains(e.Clients, q.Client) {
+		return false
+	}
+	if !q.From.IsZero() && e.Start.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && e.Start.After(q.To) {
+		return false
+	}
+	switch q.Status {
+	case "pass":
+		return e.Fails == 0
+	case "fail":
+		return e.Fails > 0
+	}
+	return true
+}
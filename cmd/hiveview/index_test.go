@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestListingIndexQuery(t *testing.T) {
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i, name := range []string{"1.json", "2.json", "3.json", "4.json"} {
+		pass := i%2 == 0
+		s := testSuite("suite", nil, clientTest("t", pass, t0.Add(time.Duration(i)*time.Hour), "geth"))
+		s.SimulatorLog = name + ".log"
+		fsys[name] = suiteFile(t, s)
+	}
+	idx, err := openListingIndex(fsys, ".", filepath.Join(t.TempDir(), "index"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.update(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q         listingQuery
+		want      []string
+		wantTotal int
+	}{
+		{listingQuery{}, []string{"4.json", "3.json", "2.json", "1.json"}, 4},
+		{listingQuery{Offset: 1, Limit: 2}, []string{"3.json", "2.json"}, 4},
+		{listingQuery{Offset: 5}, nil, 4},
+		{listingQuery{Status: "fail"}, []string{"4.json", "2.json"}, 2},
+		{listingQuery{Status: "pass", Limit: 1}, []string{"3.json"}, 2},
+		{listingQuery{Client: "besu"}, nil, 0},
+	}
+	for _, test := range tests {
+		entries, total := idx.query(test.q)
+		var files []string
+		for _, e := range entries {
+			files = append(files, e.FileName)
+		}
+		if !reflect.DeepEqual(files, test.want) || total != test.wantTotal {
+			t.Errorf("query %+v: got %v (total %d), want %v (total %d)", test.q, files, total, test.want, test.wantTotal)
+		}
+	}
+
+	// Deleted files are removed by the next update.
+	delete(fsys, "4.json")
+	if err := idx.update(); err != nil {
+		t.Fatal(err)
+	}
+	if _, total := idx.query(listingQuery{}); total != 3 {
+		t.Errorf("wrong total %d after deleting file", total)
+	}
+}
+
+func TestListingIndexBackground(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.json": suiteFile(t, testSuite("suite", nil, testCase("t", 0, true))),
+	}
+	idx, err := openListingIndex(fsys, ".", filepath.Join(t.TempDir(), "index"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.start()
+	for i := 0; i < 100; i++ {
+		if _, total := idx.query(listingQuery{}); total == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, total := idx.query(listingQuery{}); total != 1 {
+		t.Error("file was not indexed in the background")
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHideDotFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.json":                &fstest.MapFile{Data: []byte("{}")},
+		".index/CURRENT":        &fstest.MapFile{Data: []byte("x")},
+		"logs/.hidden/file.log": &fstest.MapFile{Data: []byte("x")},
+	}
+	h := http.StripPrefix("/results/", hideDotFiles(http.FileServer(http.FS(fsys))))
+	tests := map[string]int{
+		"/results/a.json":                http.StatusOK,
+		"/results/.index/CURRENT":        http.StatusNotFound,
+		"/results/logs/.hidden/file.log": http.StatusNotFound,
+	}
+	for path, want := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: status %d, want %d", path, w.Code, want)
+		}
+	}
+}
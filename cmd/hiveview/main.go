@@ -7,6 +7,8 @@ import (
 	"log"
 	"os"
 	"time"
+
+	"github.com/ethereum/hive/internal/logstore"
 )
 
 const (
@@ -21,11 +23,17 @@ func main() {
 		gc             = flag.Bool("gc", false, "Deletes old log files")
 		gcKeepInterval = flag.Duration("keep", 5*durationMonth, "Time interval of past log files to keep (for -gc)")
 		gcKeepMin      = flag.Int("keep-min", 10, "Minmum number of suite outputs to keep (for -gc)")
+		gcKeepClient   = flag.Int("keep-per-client", 0, "Number of latest runs to keep for each suite and client (for -gc)")
+		gcKeepFailures = flag.Bool("keep-failures", false, "Keep runs in which a previously passing test failed (for -gc)")
+		gcMaxSize      = flag.String("max-size", "", "Size budget of the log directory, e.g. 500G. Oldest runs are deleted first (for -gc)")
+		gcDryRun       = flag.Bool("dry-run", false, "Print the files which would be deleted, without deleting them (for -gc)")
 		config         serverConfig
 	)
 	flag.StringVar(&config.listenAddr, "addr", "0.0.0.0:8080", "HTTP server listen address")
-	flag.StringVar(&config.logDir, "logdir", "workspace/logs", "Path to hive simulator log directory")
+	flag.StringVar(&config.logDir, "logdir", "workspace/logs", "Path to hive simulator log directory, or S3 location (s3://bucket/prefix)")
 	flag.StringVar(&config.assetsDir, "assets", "", "Path to static files directory. Serves baked-in assets when not set.")
+	flag.BoolVar(&config.indexLogs, "search.logs", false, "Makes client logs searchable (this makes the index much larger)")
+	flag.StringVar(&config.indexDir, "index", "", "Path to the listing index database (default: in the user cache directory)")
 	flag.Parse()
 
 	log.SetFlags(log.LstdFlags)
@@ -33,12 +41,34 @@ func main() {
 	case *serve:
 		runServer(config)
 	case *listing:
-		fsys := os.DirFS(config.logDir)
-		generateListing(fsys, ".", os.Stdout)
+		generateListing(openLogDir(config.logDir), ".", os.Stdout)
 	case *gc:
-		cutoff := time.Now().Add(-*gcKeepInterval)
-		logdirGC(config.logDir, cutoff, *gcKeepMin)
+		policy := gcPolicy{
+			cutoff:        time.Now().Add(-*gcKeepInterval),
+			keepMin:       *gcKeepMin,
+			keepPerClient: *gcKeepClient,
+			keepFailures:  *gcKeepFailures,
+			dryRun:        *gcDryRun,
+		}
+		if *gcMaxSize != "" {
+			size, err := parseByteSize(*gcMaxSize)
+			if err != nil {
+				log.Fatalf("-max-size: %v", err)
+			}
+			policy.maxSize = size
+		}
+		if err := logdirGC(openLogDir(config.logDir), policy); err != nil {
+			log.Fatalf("GC failed: %v", err)
+		}
 	default:
 		log.Fatalf("Use -serve or -listing to select mode")
 	}
 }
+
+func openLogDir(location string) logstore.Store {
+	store, err := logstore.Open(location)
+	if err != nil {
+		log.Fatalf("-logdir: %v", err)
+	}
+	return store
+}
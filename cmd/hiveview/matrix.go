@@ -0,0 +1,231 @@
+package main
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+const (
+	matrixDefaultRuns = 10  // number of runs per cell, by default
+	matrixMaxRuns     = 100 // max number of runs per cell
+)
+
+// compatMatrix is the client compatibility matrix. Rows are test suites, columns are
+// clients.
+type compatMatrix struct {
+	Clients []string     `json:"clients"`
+	Suites  []*matrixRow `json:"suites"`
+}
+
+type matrixRow struct {
+	Name  string                 `json:"name"`
+	Cells map[string]*matrixCell `json:"cells"` // keyed by client name
+}
+
+// matrixCell contains the results of a client in the latest runs of a suite.
+type matrixCell struct {
+	Runs     []matrixRun `json:"runs"` // newest first
+	Passes   int         `json:"passes"`
+	Fails    int         `json:"fails"`
+	PassRate float64     `json:"passRate"`
+	Trend    int         `json:"trend"` // 1 if the latest run is better than the previous one, -1 if worse
+}
+
+type matrixRun struct {
+	FileName string    `json:"fileName"`
+	Start    time.Time `json:"start"`
+	Passes   int       `json:"passes"`
+	Fails    int       `json:"fails"`
+}
+
+func (r matrixRun) passRate() float64 {
+	if r.Passes+r.Fails == 0 {
+		return 0
+	}
+	return float64(r.Passes) / float64(r.Passes+r.Fails)
+}
+
+// matrixCache holds the per-client results of suite files. Entries are reused as long as
+// the modification time and size of the file are unchanged.
+type matrixCache struct {
+	mu    sync.Mutex
+	files map[string]*suiteClientStats
+}
+
+// suiteClientStats is the cached summary of a suite file.
+type suiteClientStats struct {
+	modTime time.Time
+	size    int64
+	valid   bool
+
+	suite   string
+	start   time.Time
+	end     time.Time
+	clients map[string]*matrixRun
+	results map[string]map[uint64]bool // test results by client, keyed by test path hash
+}
+
+func newMatrixCache() *matrixCache {
+	return &matrixCache{files: make(map[string]*suiteClientStats)}
+}
+
+// build computes the matrix from the latest nruns runs of each suite/client combination.
+func (c *matrixCache) build(fsys fs.FS, dir string, nruns int) (*compatMatrix, error) {
+	stats, err := c.update(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rows    = make(map[string]*matrixRow)
+		clients = make(map[string]struct{})
+	)
+	// stats is sorted newest-first, so runs are added to cells in that order.
+	for _, s := range stats {
+		row := rows[s.suite]
+		if row == nil {
+			row = &matrixRow{Name: s.suite, Cells: make(map[string]*matrixCell)}
+			rows[s.suite] = row
+		}
+		for client, run := range s.clients {
+			clients[client] = struct{}{}
+			cell := row.Cells[client]
+			if cell == nil {
+				cell = new(matrixCell)
+				row.Cells[client] = cell
+			}
+			if len(cell.Runs) < nruns {
+				cell.Runs = append(cell.Runs, *run)
+				cell.Passes += run.Passes
+				cell.Fails += run.Fails
+			}
+		}
+	}
+
+	m := &compatMatrix{Clients: make([]string, 0, len(clients)), Suites: make([]*matrixRow, 0, len(rows))}
+	for client := range clients {
+		m.Clients = append(m.Clients, client)
+	}
+	sort.Strings(m.Clients)
+	for _, row := range rows {
+		for _, cell := range row.Cells {
+			if cell.Passes+cell.Fails > 0 {
+				cell.PassRate = float64(cell.Passes) / float64(cell.Passes+cell.Fails)
+			}
+			if len(cell.Runs) > 1 {
+				latest, prev := cell.Runs[0].passRate(), cell.Runs[1].passRate()
+				switch {
+				case latest > prev:
+					cell.Trend = 1
+				case latest < prev:
+					cell.Trend = -1
+				}
+			}
+		}
+		m.Suites = append(m.Suites, row)
+	}
+	sort.Slice(m.Suites, func(i, j int) bool { return m.Suites[i].Name < m.Suites[j].Name })
+	return m, nil
+}
+
+// update refreshes the cache and returns the stats of all valid suite files,
+// newest-first.
+func (c *matrixCache) update(fsys fs.FS, dir string) ([]*suiteClientStats, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		present = make(map[string]struct{}, len(entries))
+		result  []*suiteClientStats
+	)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || skipFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		present[name] = struct{}{}
+		s := c.files[name]
+		if s == nil || !s.modTime.Equal(info.ModTime()) || s.size != info.Size() {
+			s = &suiteClientStats{modTime: info.ModTime(), size: info.Size()}
+			if suite, _ := parseSuite(fsys, path.Join(dir, name)); suite != nil {
+				s.setSuite(suite, name)
+			}
+			c.files[name] = s
+		}
+		if s.valid {
+			result = append(result, s)
+		}
+	}
+
+	// Drop entries of deleted files.
+	for name := range c.files {
+		if _, ok := present[name]; !ok {
+			delete(c.files, name)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].start.After(result[j].start)
+	})
+	return result, nil
+}
+
+// setSuite computes the per-client results of a suite. Test cases count for all
+// clients they have started.
+func (s *suiteClientStats) setSuite(suite *libhive.TestSuite, file string) {
+	s.valid = true
+	s.suite = suite.Name
+	s.clients = make(map[string]*matrixRun)
+	s.results = make(map[string]map[uint64]bool)
+	for _, test := range suite.TestCases {
+		if s.start.IsZero() || test.Start.Before(s.start) {
+			s.start = test.Start
+		}
+		if test.End.After(s.end) {
+			s.end = test.End
+		}
+	}
+	for _, test := range suite.TestCases {
+		counted := make(map[string]bool)
+		for _, client := range test.ClientInfo {
+			if counted[client.Name] {
+				continue
+			}
+			counted[client.Name] = true
+			run := s.clients[client.Name]
+			if run == nil {
+				run = &matrixRun{FileName: file, Start: s.start}
+				s.clients[client.Name] = run
+				s.results[client.Name] = make(map[uint64]bool)
+			}
+			s.results[client.Name][testPathHash(suite, test)] = test.SummaryResult.Pass
+			if test.SummaryResult.Pass {
+				run.Passes++
+			} else {
+				run.Fails++
+			}
+		}
+	}
+}
+
+func testPathHash(s *libhive.TestSuite, test *libhive.TestCase) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(testPath(s, test)))
+	return h.Sum64()
+}
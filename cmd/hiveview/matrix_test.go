@@ -0,0 +1,167 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+// clientTest creates a test case which ran the given clients.
+func clientTest(name string, pass bool, start time.Time, clients ...string) *libhive.TestCase {
+	test := testCase(name, 0, pass)
+	test.Start, test.End = start, start.Add(time.Minute)
+	test.ClientInfo = make(map[string]*libhive.ClientInfo)
+	for i, client := range clients {
+		id := string(rune('a' + i))
+		test.ClientInfo[id] = &libhive.ClientInfo{ID: id, Name: client}
+	}
+	return test
+}
+
+func TestMatrixBuild(t *testing.T) {
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		// Runs of suite "a", oldest first.
+		"1.json": suiteFile(t, testSuite("a", nil,
+			clientTest("t1", true, t0, "geth"),
+			clientTest("t2", false, t0, "geth"),
+			clientTest("t1", true, t0, "besu"),
+		)),
+		"2.json": suiteFile(t, testSuite("a", nil,
+			clientTest("t1", true, t0.Add(time.Hour), "geth"),
+			clientTest("t2", true, t0.Add(time.Hour), "geth"),
+			clientTest("t1", true, t0.Add(time.Hour), "besu"),
+		)),
+		"3.json": suiteFile(t, testSuite("a", nil,
+			clientTest("t1", false, t0.Add(2*time.Hour), "geth"),
+			clientTest("t2", true, t0.Add(2*time.Hour), "geth"),
+			clientTest("t1", false, t0.Add(2*time.Hour), "besu"),
+		)),
+		// A test with two instances of a client counts once.
+		"4.json": suiteFile(t, testSuite("b", nil,
+			clientTest("t1", true, t0, "nethermind", "nethermind"),
+		)),
+		"errorReport.json": &fstest.MapFile{Data: []byte("{}")},
+		"invalid.json":     &fstest.MapFile{Data: []byte("{")},
+	}
+
+	m, err := newMatrixCache().build(fsys, ".", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"besu", "geth", "nethermind"}; !reflect.DeepEqual(m.Clients, want) {
+		t.Fatalf("wrong clients %v, want %v", m.Clients, want)
+	}
+	if len(m.Suites) != 2 || m.Suites[0].Name != "a" || m.Suites[1].Name != "b" {
+		t.Fatalf("wrong suites: %+v", m.Suites)
+	}
+
+	tests := []struct {
+		suite, client string
+		runs          []string
+		passes, fails int
+		trend         int
+	}{
+		{"a", "geth", []string{"3.json", "2.json"}, 3, 1, -1},
+		{"a", "besu", []string{"3.json", "2.json"}, 1, 1, -1},
+		{"b", "nethermind", []string{"4.json"}, 1, 0, 0},
+	}
+	for _, test := range tests {
+		var row *matrixRow
+		for _, r := range m.Suites {
+			if r.Name == test.suite {
+				row = r
+			}
+		}
+		cell := row.Cells[test.client]
+		if cell == nil {
+			t.Errorf("%s/%s: missing cell", test.suite, test.client)
+			continue
+		}
+		var runs []string
+		for _, run := range cell.Runs {
+			runs = append(runs, run.FileName)
+		}
+		if !reflect.DeepEqual(runs, test.runs) {
+			t.Errorf("%s/%s: wrong runs %v, want %v", test.suite, test.client, runs, test.runs)
+		}
+		if cell.Passes != test.passes || cell.Fails != test.fails {
+			t.Errorf("%s/%s: wrong results %d/%d, want %d/%d", test.suite, test.client, cell.Passes, cell.Fails, test.passes, test.fails)
+		}
+		if rate := float64(test.passes) / float64(test.passes+test.fails); cell.PassRate != rate {
+			t.Errorf("%s/%s: wrong pass rate %f, want %f", test.suite, test.client, cell.PassRate, rate)
+		}
+		if cell.Trend != test.trend {
+			t.Errorf("%s/%s: wrong trend %d, want %d", test.suite, test.client, cell.Trend, test.trend)
+		}
+	}
+	if row := m.Suites[1]; row.Cells["geth"] != nil {
+		t.Error("suite b has cell for client without runs")
+	}
+}
+
+func TestMatrixTrend(t *testing.T) {
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		prev, latest []bool
+		trend        int
+	}{
+		{[]bool{true, false}, []bool{true, true}, 1},
+		{[]bool{true, true}, []bool{true, false}, -1},
+		{[]bool{true, false}, []bool{false, true}, 0},
+	}
+	for i, test := range tests {
+		var prev, latest []*libhive.TestCase
+		for j, pass := range test.prev {
+			prev = append(prev, clientTest(string(rune('a'+j)), pass, t0, "geth"))
+		}
+		for j, pass := range test.latest {
+			latest = append(latest, clientTest(string(rune('a'+j)), pass, t0.Add(time.Hour), "geth"))
+		}
+		fsys := fstest.MapFS{
+			"prev.json":   suiteFile(t, testSuite("s", nil, prev...)),
+			"latest.json": suiteFile(t, testSuite("s", nil, latest...)),
+		}
+		m, err := newMatrixCache().build(fsys, ".", matrixDefaultRuns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trend := m.Suites[0].Cells["geth"].Trend; trend != test.trend {
+			t.Errorf("test %d: wrong trend %d, want %d", i, trend, test.trend)
+		}
+	}
+}
+
+func TestMatrixCacheUpdate(t *testing.T) {
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"1.json": suiteFile(t, testSuite("a", nil, clientTest("t1", true, t0, "geth"))),
+		"2.json": suiteFile(t, testSuite("a", nil, clientTest("t1", true, t0.Add(time.Hour), "geth"))),
+	}
+	c := newMatrixCache()
+	stats, err := c.update(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].clients["geth"].FileName != "2.json" {
+		t.Fatalf("wrong stats after first update")
+	}
+
+	// Changed files are parsed again, deleted files are dropped.
+	fsys["1.json"] = suiteFile(t, testSuite("a", nil, clientTest("t1", false, t0.Add(2*time.Hour), "geth")))
+	fsys["1.json"].ModTime = t0.Add(time.Second)
+	delete(fsys, "2.json")
+	stats, err = c.update(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].clients["geth"].Fails != 1 {
+		t.Fatalf("changed file was not updated")
+	}
+	if len(c.files) != 1 {
+		t.Fatalf("deleted file is still cached")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metricsFlakyRuns is the number of latest runs checked for flaky tests.
+const metricsFlakyRuns = 10
+
+// serveMetrics serves metrics about the results in Prometheus text format. All metrics
+// are labeled by suite and client.
+type serveMetrics struct {
+	fsys  fs.FS
+	cache *matrixCache
+}
+
+// suiteClientMetrics are the metrics of a suite and client.
+type suiteClientMetrics struct {
+	suite, client string
+
+	runs    int
+	latest  *matrixRun
+	lastRun *suiteClientStats
+	flaky   int
+
+	// results of recent runs, used for flaky test detection
+	recent []map[uint64]bool
+}
+
+func (h serveMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.cache.update(h.fsys, ".")
+	if err != nil {
+		log.Printf("Can't compute metrics: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	metrics := collectMetrics(stats)
+
+	w.Header().Set("content-type", "text/plain; version=0.0.4")
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	writeMetric(out, metrics, "hive_runs", "gauge", "Number of suite runs in the log directory.",
+		func(m *suiteClientMetrics) float64 { return float64(m.runs) })
+	writeMetric(out, metrics, "hive_tests", "gauge", "Number of tests in the latest run.",
+		func(m *suiteClientMetrics) float64 { return float64(m.latest.Passes + m.latest.Fails) })
+	writeMetric(out, metrics, "hive_tests_failed", "gauge", "Number of failed tests in the latest run.",
+		func(m *suiteClientMetrics) float64 { return float64(m.latest.Fails) })
+	writeMetric(out, metrics, "hive_tests_pass_ratio", "gauge", "Ratio of passing tests in the latest run.",
+		func(m *suiteClientMetrics) float64 { return m.latest.passRate() })
+	writeMetric(out, metrics, "hive_last_run_timestamp_seconds", "gauge", "Start time of the latest run.",
+		func(m *suiteClientMetrics) float64 { return float64(m.lastRun.start.UnixNano()) / 1e9 })
+	writeMetric(out, metrics, "hive_last_run_duration_seconds", "gauge", "Duration of the latest run.",
+		func(m *suiteClientMetrics) float64 { return m.lastRun.end.Sub(m.lastRun.start).Seconds() })
+	writeMetric(out, metrics, "hive_tests_flaky", "gauge",
+		fmt.Sprintf("Number of tests which both passed and failed in the latest %d runs.", metricsFlakyRuns),
+		func(m *suiteClientMetrics) float64 { return float64(m.flaky) })
+}
+
+// collectMetrics computes the metrics of all suite/client combinations. The stats must
+// be sorted newest-first.
+func collectMetrics(stats []*suiteClientStats) []*suiteClientMetrics {
+	byKey := make(map[string]*suiteClientMetrics)
+	for _, s := range stats {
+		for client, run := range s.clients {
+			key := s.suite + "\x00" + client
+			m := byKey[key]
+			if m == nil {
+				m = &suiteClientMetrics{suite: s.suite, client: client, latest: run, lastRun: s}
+				byKey[key] = m
+			}
+			m.runs++
+			if len(m.recent) < metricsFlakyRuns {
+				m.recent = append(m.recent, s.results[client])
+			}
+		}
+	}
+
+	list := make([]*suiteClientMetrics, 0, len(byKey))
+	for _, m := range byKey {
+		m.flaky = countFlaky(m.recent)
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].suite != list[j].suite {
+			return list[i].suite < list[j].suite
+		}
+		return list[i].client < list[j].client
+	})
+	return list
+}
+
+// countFlaky returns the number of tests which passed in some runs and failed in others.
+func countFlaky(runs []map[uint64]bool) int {
+	const passed, failed = 1, 2
+	outcomes := make(map[uint64]int)
+	for _, results := range runs {
+		for test, pass := range results {
+			if pass {
+				outcomes[test] |= passed
+			} else {
+				outcomes[test] |= failed
+			}
+		}
+	}
+	var n int
+	for _, o := range outcomes {
+		if o == passed|failed {
+			n++
+		}
+	}
+	return n
+}
+
+func writeMetric(w *bufio.Writer, metrics []*suiteClientMetrics, name, typ, help string, value func(*suiteClientMetrics) float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	for _, m := range metrics {
+		fmt.Fprintf(w, "%s{suite=\"%s\",client=\"%s\"} %s\n", name, escapeLabel(m.suite), escapeLabel(m.client),
+			strconv.FormatFloat(value(m), 'f', -1, 64))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestServeMetrics(t *testing.T) {
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"1.json": suiteFile(t, testSuite("suite", nil,
+			clientTest("a", true, t0, "geth"),
+			clientTest("b", true, t0, "geth"),
+		)),
+		"2.json": suiteFile(t, testSuite("suite", nil,
+			clientTest("a", true, t0.Add(time.Hour), "geth"),
+			clientTest("b", false, t0.Add(time.Hour), "geth"),
+		)),
+	}
+	w := httptest.NewRecorder()
+	serveMetrics{fsys: fsys, cache: newMatrixCache()}.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	output := w.Body.String()
+
+	for _, line := range []string{
+		"# TYPE hive_runs gauge",
+		`hive_runs{suite="suite",client="geth"} 2`,
+		`hive_tests{suite="suite",client="geth"} 2`,
+		`hive_tests_failed{suite="suite",client="geth"} 1`,
+		`hive_tests_pass_ratio{suite="suite",client="geth"} 0.5`,
+		`hive_last_run_timestamp_seconds{suite="suite",client="geth"} 1640998800`,
+		`hive_tests_flaky{suite="suite",client="geth"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("output does not contain %q", line)
+		}
+	}
+	if t.Failed() {
+		t.Log("output:\n" + output)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/hive/internal/libhive"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	searchMinTokenLen   = 2
+	searchMaxTokenLen   = 32        // longer tokens (e.g. hashes) are not indexed
+	searchMaxLogSize    = 32 << 20  // max bytes of a client log which are indexed
+	searchMaxQueryRead  = 128 << 20 // max bytes of client logs read per query
+	searchMaxCandidates = 5000      // max number of documents checked per query
+	searchSnippetChars  = 100       // context chars on each side of a match
+)
+
+var (
+	searchWordPrefix = []byte("word:")
+	searchDocPrefix  = []byte("doc:")
+
+	errSearchQueryTooShort = errors.New("search query has no indexed words (2-32 characters)")
+)
+
+// searchDoc is a searchable document: the details of a test case, or a client log file
+// which belongs to a test case.
+type searchDoc struct {
+	File      string         `json:"file"`
+	SuiteName string         `json:"suiteName"`
+	TestID    libhive.TestID `json:"testID"`
+	TestName  string         `json:"testName"`
+	LogFile   string         `json:"logFile,omitempty"` // set for client logs
+	Details   string         `json:"details,omitempty"` // set for test details
+	Tokens    []string       `json:"tokens"`
+}
+
+func (d *searchDoc) key() string {
+	return d.File + "\x00" + strconv.FormatUint(uint64(d.TestID), 10) + "\x00" + d.LogFile
+}
+
+// searchResult is a match of a search query.
+type searchResult struct {
+	File      string         `json:"fileName"`
+	SuiteName string         `json:"suiteName"`
+	TestID    libhive.TestID `json:"testID"`
+	TestName  string         `json:"testName"`
+	LogFile   string         `json:"logFile,omitempty"`
+	Snippet   string         `json:"snippet"`
+}
+
+// indexSuiteText adds the searchable content of a suite file to the index.
+func (idx *listingIndex) indexSuiteText(batch *leveldb.Batch, file string, suite *libhive.TestSuite) {
+	for id, test := range suite.TestCases {
+		doc := &searchDoc{File: file, SuiteName: suite.Name, TestID: id, TestName: test.Name}
+		if test.SummaryResult.Details != "" {
+			doc.Details = test.SummaryResult.Details
+			doc.Tokens = tokenize(doc.Details)
+			idx.putSearchDoc(batch, doc)
+		}
+		if !idx.indexLogs {
+			continue
+		}
+		for _, client := range test.ClientInfo {
+			text, err := idx.readLog(client.LogFile, searchMaxLogSize)
+			if err != nil {
+				continue
+			}
+			logDoc := &searchDoc{File: file, SuiteName: suite.Name, TestID: id, TestName: test.Name, LogFile: client.LogFile}
+			logDoc.Tokens = tokenize(text)
+			idx.putSearchDoc(batch, logDoc)
+		}
+	}
+}
+
+func (idx *listingIndex) putSearchDoc(batch *leveldb.Batch, doc *searchDoc) {
+	if len(doc.Tokens) == 0 {
+		return
+	}
+	key := doc.key()
+	enc, _ := json.Marshal(doc)
+	batch.Put(append(append([]byte{}, searchDocPrefix...), key...), enc)
+	for _, token := range doc.Tokens {
+		batch.Put(searchWordKey(token, key), nil)
+	}
+}
+
+// deleteSuiteText removes the searchable content of a suite file from the index.
+func (idx *listingIndex) deleteSuiteText(batch *leveldb.Batch, file string) {
+	prefix := append(append([]byte{}, searchDocPrefix...), file+"\x00"...)
+	it := idx.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+	for it.Next() {
+		var doc searchDoc
+		if err := json.Unmarshal(it.Value(), &doc); err == nil {
+			key := doc.key()
+			for _, token := range doc.Tokens {
+				batch.Delete(searchWordKey(token, key))
+			}
+		}
+		batch.Delete(append([]byte{}, it.Key()...))
+	}
+}
+
+func searchWordKey(token, docKey string) []byte {
+	key := append(append([]byte{}, searchWordPrefix...), token...)
+	key = append(key, 0)
+	return append(key, docKey...)
+}
+
+// readLog reads up to limit bytes from the beginning of a client log file.
+func (idx *listingIndex) readLog(name string, limit int64) (string, error) {
+	f, err := idx.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	text, err := io.ReadAll(io.LimitReader(f, limit))
+	return string(text), err
+}
+
+// search returns the test details and client logs containing the query text. Results
+// are sorted newest-first. Client logs are only checked until idx.searchReadLimit
+// bytes of them have been read.
+func (idx *listingIndex) search(query string, limit int) ([]searchResult, error) {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil, errSearchQueryTooShort
+	}
+
+	// Find documents containing all query tokens.
+	var candidates map[string]bool
+	for _, token := range tokens {
+		docs := make(map[string]bool)
+		prefix := searchWordKey(token, "")
+		it := idx.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for it.Next() {
+			key := string(it.Key()[len(prefix):])
+			if candidates == nil || candidates[key] {
+				docs[key] = true
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+		candidates = docs
+		if len(candidates) == 0 {
+			return []searchResult{}, nil
+		}
+	}
+	keys := make([]string, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	// Suite file names start with a timestamp, so this sorts newest-first.
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	if len(keys) > searchMaxCandidates {
+		keys = keys[:searchMaxCandidates]
+	}
+
+	// Check that the documents contain the query as a phrase.
+	var (
+		results = []searchResult{}
+		budget  = idx.searchReadLimit
+	)
+	for _, key := range keys {
+		enc, err := idx.db.Get(append(append([]byte{}, searchDocPrefix...), key...), nil)
+		if err != nil {
+			continue
+		}
+		var doc searchDoc
+		if err := json.Unmarshal(enc, &doc); err != nil {
+			continue
+		}
+		text := doc.Details
+		if doc.LogFile != "" {
+			limit := int64(searchMaxLogSize)
+			if budget < limit {
+				limit = budget
+			}
+			if limit <= 0 {
+				continue
+			}
+			if text, err = idx.readLog(doc.LogFile, limit); err != nil {
+				continue
+			}
+			budget -= int64(len(text))
+		}
+		snippet, ok := searchSnippet(text, query)
+		if !ok {
+			continue
+		}
+		results = append(results, searchResult{
+			File:      doc.File,
+			SuiteName: doc.SuiteName,
+			TestID:    doc.TestID,
+			TestName:  doc.TestName,
+			LogFile:   doc.LogFile,
+			Snippet:   snippet,
+		})
+		if len(results) >= limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// tokenize splits text into lower-case words. Each word is returned once.
+func tokenize(text string) []string {
+	var (
+		seen   = make(map[string]struct{})
+		tokens []string
+	)
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if len(w) < searchMinTokenLen || len(w) > searchMaxTokenLen {
+			continue
+		}
+		w = strings.ToLower(w)
+		if _, ok := seen[w]; !ok {
+			seen[w] = struct{}{}
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// searchSnippet finds the query in text (ignoring case) and returns the surrounding text.
+func searchSnippet(text, query string) (string, bool) {
+	lower := strings.ToLower(text)
+	pos := strings.Index(lower, strings.ToLower(query))
+	if pos < 0 {
+		return "", false
+	}
+	if len(lower) != len(text) {
+		text = lower // positions differ, show the lower-case text.
+	}
+	start, end := pos-searchSnippetChars, pos+len(query)+searchSnippetChars
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	// Don't cut UTF-8 sequences.
+	for start > 0 && !utf8Start(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8Start(text[end]) {
+		end++
+	}
+	return text[start:end], true
+}
+
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestTokenize(t *testing.T) {
+	long := strings.Repeat("x", searchMaxTokenLen+1)
+	tokens := tokenize("Connection refused: connection to 10.0.0.1 failed, a " + long + " Ünïcode")
+	want := []string{"connection", "refused", "to", "10", "failed", "ünïcode"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("wrong tokens %q, want %q", tokens, want)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	if _, ok := searchSnippet("some text", "missing"); ok {
+		t.Error("snippet found for missing query")
+	}
+	if s, ok := searchSnippet("Error: Connection Refused", "connection refused"); !ok || s != "Error: Connection Refused" {
+		t.Errorf("wrong snippet %q", s)
+	}
+
+	// Long text is cut around the match.
+	text := strings.Repeat("a", 500) + "needle" + strings.Repeat("b", 500)
+	s, ok := searchSnippet(text, "needle")
+	want := strings.Repeat("a", searchSnippetChars) + "needle" + strings.Repeat("b", searchSnippetChars)
+	if !ok || s != want {
+		t.Errorf("wrong snippet of long text %q", s)
+	}
+
+	// Multi-byte characters at the edges are not cut.
+	text = strings.Repeat("ä", 200) + "needle" + strings.Repeat("ö", 200)
+	s, ok = searchSnippet(text, "needle")
+	if !ok || !strings.HasPrefix(s, "ä") || !strings.HasSuffix(s, "ö") || !strings.Contains(s, "needle") {
+		t.Errorf("wrong snippet of UTF-8 text %q", s)
+	}
+}
+
+func searchTestFS(t *testing.T) fstest.MapFS {
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i, name := range []string{"1.json", "2.json"} {
+		test := clientTest("t", false, t0.Add(time.Duration(i)*time.Hour), "geth")
+		test.SummaryResult.Details = "dial failed: connection refused"
+		logFile := "geth-" + name + ".log"
+		test.ClientInfo["a"].LogFile = logFile
+		fsys[name] = suiteFile(t, testSuite("suite", nil, test))
+		fsys[logFile] = &fstest.MapFile{Data: []byte("INFO started\nWARN peer dropped: too many peers\n")}
+	}
+	return fsys
+}
+
+func searchNames(results []searchResult) []string {
+	var names []string
+	for _, r := range results {
+		name := r.File
+		if r.LogFile != "" {
+			name += " " + r.LogFile
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestSearch(t *testing.T) {
+	fsys := searchTestFS(t)
+	idx, err := openListingIndex(fsys, ".", filepath.Join(t.TempDir(), "index"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.update(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{"Connection Refused", 10, []string{"2.json", "1.json"}},
+		{"connection refused", 1, []string{"2.json"}},
+		{"refused connection", 10, nil}, // words present, but not as a phrase
+		{"too many peers", 10, []string{"2.json geth-2.json.log", "1.json geth-1.json.log"}},
+		{"unknown", 10, nil},
+	}
+	for _, test := range tests {
+		results, err := idx.search(test.query, test.limit)
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+		if names := searchNames(results); !reflect.DeepEqual(names, test.want) {
+			t.Errorf("%q: wrong results %v, want %v", test.query, names, test.want)
+		}
+	}
+	if results, _ := idx.search("peer dropped", 1); len(results) != 1 || !strings.Contains(results[0].Snippet, "WARN peer dropped") {
+		t.Errorf("wrong snippet in results %+v", results)
+	}
+	if _, err := idx.search("a !", 10); !errors.Is(err, errSearchQueryTooShort) {
+		t.Errorf("wrong error for short query: %v", err)
+	}
+
+	// Client logs are not read beyond the read limit of a query.
+	idx.searchReadLimit = 1
+	if results, _ := idx.search("too many peers", 10); len(results) != 0 {
+		t.Errorf("logs read beyond limit: %v", searchNames(results))
+	}
+}
+
+func TestDeleteSuiteText(t *testing.T) {
+	fsys := searchTestFS(t)
+	idx, err := openListingIndex(fsys, ".", filepath.Join(t.TempDir(), "index"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.update(); err != nil {
+		t.Fatal(err)
+	}
+
+	delete(fsys, "2.json")
+	if err := idx.update(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := idx.search("connection refused", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := searchNames(results); !reflect.DeepEqual(names, []string{"1.json"}) {
+		t.Errorf("wrong results after delete: %v", names)
+	}
+
+	// No documents or words of the deleted file are left.
+	for _, prefix := range [][]byte{searchDocPrefix, searchWordPrefix} {
+		it := idx.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for it.Next() {
+			if strings.Contains(string(it.Key()), "2.json") {
+				t.Errorf("key %q of deleted file is still indexed", it.Key())
+			}
+		}
+		it.Release()
+	}
+}
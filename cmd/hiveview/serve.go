@@ -1,13 +1,24 @@
 package main
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ethereum/hive/internal/logstore"
 	"github.com/gorilla/mux"
 )
 
@@ -18,6 +29,8 @@ type serverConfig struct {
 	listenAddr string
 	logDir     string
 	assetsDir  string
+	indexDir   string
+	indexLogs  bool
 }
 
 func runServer(config serverConfig) {
@@ -36,12 +49,29 @@ func runServer(config serverConfig) {
 	}
 
 	// Create handlers.
-	logDirFS := os.DirFS(config.logDir)
+	logDirFS := openLogDir(config.logDir)
 	logHandler := http.FileServer(http.FS(logDirFS))
 	listingHandler := serveListing{fsys: logDirFS}
+	indexDir := config.indexDir
+	if indexDir == "" {
+		indexDir = defaultIndexDir(config.logDir)
+	}
+	index, err := openListingIndex(logDirFS, ".", indexDir, config.indexLogs)
+	if err != nil {
+		log.Printf("Can't open listing index, listing will be slow: %v", err)
+	} else {
+		index.start()
+		defer index.Close()
+		listingHandler.index = index
+	}
 	mux := mux.NewRouter()
 	mux.Handle("/listing.jsonl", listingHandler).Methods("GET")
-	mux.PathPrefix("/results").Handler(http.StripPrefix("/results/", logHandler))
+	mux.Handle("/search", serveSearch{index: listingHandler.index}).Methods("GET")
+	mux.Handle("/compare", serveCompare{fsys: logDirFS}).Methods("GET")
+	matrixCache := newMatrixCache()
+	mux.Handle("/matrix.json", serveMatrix{fsys: logDirFS, cache: matrixCache}).Methods("GET")
+	mux.Handle("/metrics", serveMetrics{fsys: logDirFS, cache: matrixCache}).Methods("GET")
+	mux.PathPrefix("/results").Handler(http.StripPrefix("/results/", hideDotFiles(logHandler)))
 	mux.PathPrefix("/").Handler(http.FileServer(http.FS(assetFS)))
 
 	// Start the server.
@@ -53,12 +83,201 @@ func runServer(config serverConfig) {
 	http.Serve(l, mux)
 }
 
-type serveListing struct{ fsys fs.FS }
+// defaultIndexDir returns the listing index location for a log directory. The index is
+// kept in the user's cache directory, so it isn't served along with the results.
+func defaultIndexDir(logDir string) string {
+	if !logstore.IsRemote(logDir) {
+		if abs, err := filepath.Abs(logDir); err == nil {
+			logDir = abs
+		}
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	hash := sha256.Sum256([]byte(logDir))
+	return filepath.Join(cacheDir, "hiveview", "index-"+hex.EncodeToString(hash[:8]))
+}
+
+// hideDotFiles responds with 404 to requests for files and directories whose name starts
+// with a dot.
+func hideDotFiles(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, elem := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(elem, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveListing serves the listing of suite runs. When the index is available, the
+// listing can be paginated and filtered using query parameters.
+type serveListing struct {
+	fsys  fs.FS
+	index *listingIndex
+}
 
 func (h serveListing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Generating listing...")
-	err := generateListing(h.fsys, ".", w)
+	if h.index == nil {
+		log.Printf("Generating listing...")
+		err := generateListing(h.fsys, ".", w)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	q, err := parseListingQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, total := h.index.query(q)
+	w.Header().Set("X-Listing-Total", strconv.Itoa(total))
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			break
+		}
+	}
+}
+
+// parseListingQuery parses the listing query parameters.
+func parseListingQuery(v url.Values) (listingQuery, error) {
+	q := listingQuery{
+		Suite:  v.Get("suite"),
+		Client: v.Get("client"),
+		Status: v.Get("status"),
+		Limit:  listLimit,
+	}
+	if q.Status != "" && q.Status != "pass" && q.Status != "fail" {
+		return q, errors.New("invalid 'status' parameter, want 'pass' or 'fail'")
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &q.Offset}, {"limit", &q.Limit}} {
+		if s := v.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid '%s' parameter", p.name)
+			}
+			*p.dst = n
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if s := v.Get(p.name); s != "" {
+			t, err := parseListingTime(s)
+			if err != nil {
+				return q, fmt.Errorf("invalid '%s' parameter: %v", p.name, err)
+			}
+			*p.dst = t
+		}
+	}
+	// A plain date in 'to' includes the whole day.
+	if s := v.Get("to"); len(s) == len("2006-01-02") {
+		q.To = q.To.Add(24*time.Hour - 1)
+	}
+	return q, nil
+}
+
+func parseListingTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// serveCompare serves the comparison of two runs. The runs are selected either by their
+// suite file names (parameters 'a' and 'b'), or as the latest runs of a suite for two
+// client versions (parameters 'suite', 'versionA' and 'versionB').
+type serveCompare struct{ fsys fs.FS }
+
+func (h serveCompare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		q   = r.URL.Query()
+		c   *comparison
+		err error
+	)
+	switch {
+	case q.Get("a") != "" && q.Get("b") != "":
+		c, err = loadComparison(h.fsys, q.Get("a"), q.Get("b"))
+	case q.Get("suite") != "" && q.Get("versionA") != "" && q.Get("versionB") != "":
+		c, err = loadVersionComparison(h.fsys, q.Get("suite"), q.Get("versionA"), q.Get("versionB"))
+	default:
+		err = errors.New("need parameters 'a' and 'b', or 'suite', 'versionA' and 'versionB'")
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNoMatchingRun) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// serveMatrix serves the client compatibility matrix. The number of runs per
+// suite/client is configured by the 'runs' parameter.
+type serveMatrix struct {
+	fsys  fs.FS
+	cache *matrixCache
+}
+
+func (h serveMatrix) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	nruns := matrixDefaultRuns
+	if v := r.URL.Query().Get("runs"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > matrixMaxRuns {
+			http.Error(w, "invalid 'runs' parameter", http.StatusBadRequest)
+			return
+		}
+		nruns = n
+	}
+	m, err := h.cache.build(h.fsys, ".", nruns)
 	if err != nil {
+		log.Printf("Can't build matrix: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// serveSearch serves full-text search results. The query is given by the 'q' parameter.
+type serveSearch struct{ index *listingIndex }
+
+func (h serveSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.index == nil {
+		http.Error(w, "search is unavailable because the index could not be opened", http.StatusServiceUnavailable)
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	results, err := h.index.search(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSearchQueryTooShort) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
 	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(results)
 }
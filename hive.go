@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,44 +11,39 @@ import (
 	"regexp"
 	"strings"
 	"syscall"
-	"time"
+	"text/tabwriter"
 
 	"github.com/ethereum/hive/internal/libdocker"
 	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/logstore"
+	"github.com/ethereum/hive/internal/simapi"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
 func main() {
 	var (
-		testResultsRoot       = flag.String("results-root", "workspace/logs", "Target `directory` for results files and logs.")
-		loglevelFlag          = flag.Int("loglevel", 3, "Log `level` for system events. Supports values 0-5.")
+		configFile            = flag.String("config", "", "Run configuration `file` (YAML). Flags given on the command line override its settings.")
 		dockerEndpoint        = flag.String("docker.endpoint", "", "Endpoint of the local Docker daemon.")
-		dockerNoCache         = flag.String("docker.nocache", "", "Regular `expression` selecting the docker images to forcibly rebuild.")
-		dockerPull            = flag.Bool("docker.pull", false, "Refresh base images when building images.")
-		dockerOutput          = flag.Bool("docker.output", false, "Relay all docker output to stderr.")
 		simPattern            = flag.String("sim", "", "Regular `expression` selecting the simulators to run.")
-		simTestPattern        = flag.String("sim.limit", "", "Regular `expression` selecting tests/suites (interpreted by simulators).")
-		simParallelism        = flag.Int("sim.parallelism", 1, "Max `number` of parallel clients/containers (interpreted by simulators).")
+		simListOnly           = flag.Bool("sim.list", false, "Lists the tests of the simulators without running them. The test catalog is printed to stdout as JSON.")
 		simTestLimit          = flag.Int("sim.testlimit", 0, "[DEPRECATED] Max `number` of tests to execute per client (interpreted by simulators).")
-		simTimeLimit          = flag.Duration("sim.timelimit", 0, "Simulation `timeout`. Hive aborts the simulator if it exceeds this time.")
-		simLogLevel           = flag.Int("sim.loglevel", 3, "Selects log `level` of client instances. Supports values 0-5.")
 		simDevMode            = flag.Bool("dev", false, "Only starts the simulator API endpoint (listening at 127.0.0.1:3000 by default) without starting any simulators.")
 		simDevModeAPIEndpoint = flag.String("dev.addr", "127.0.0.1:3000", "Endpoint that the simulator API listens on")
-
-		clients = flag.String("client", "go-ethereum", "Comma separated `list` of clients to use. Client names in the list may be given as\n"+
-			"just the client name, or a client_branch specifier. If a branch name is supplied,\n"+
-			"the client image will use the given git branch or docker tag. Multiple instances of\n"+
-			"a single client type may be requested with different branches.\n"+
-			"Example: \"besu_latest,besu_20.10.2\"")
-		clientTimeout = flag.Duration("client.checktimelimit", 3*time.Minute, "The `timeout` of waiting for clients to open up the RPC port.\n"+
-			"If a very long chain is imported, this timeout may need to be quite large.\n"+
-			"A lower value means that hive won't wait as long in case the node crashes and\n"+
-			"never opens the RPC port.")
+		listInventory         = flag.Bool("list", false, "Lists the available clients and simulators with their metadata, without running anything.\n"+
+			"The simulator list can be filtered with --sim.")
 	)
 
+	// These flags can also be set in the config file. Their values are read
+	// through the run configuration, see loadRunConfig.
+	addRunConfigFlags(flag.CommandLine)
+
 	// Parse the flags and configure the logger.
 	flag.Parse()
-	log15.Root().SetHandler(log15.LvlFilterHandler(log15.Lvl(*loglevelFlag), log15.StreamHandler(os.Stderr, log15.TerminalFormat())))
+	cfg, err := loadRunConfig(flag.CommandLine, *configFile)
+	if err != nil {
+		fatal(err)
+	}
+	log15.Root().SetHandler(log15.LvlFilterHandler(log15.Lvl(cfg.LogLevel), log15.StreamHandler(os.Stderr, log15.TerminalFormat())))
 
 	if *simTestLimit > 0 {
 		log15.Warn("Option --sim.testlimit is deprecated and will have no effect.")
@@ -58,14 +54,27 @@ func main() {
 	if err != nil {
 		fatal(err)
 	}
-	simList, err := inv.MatchSimulators(*simPattern)
-	if err != nil {
-		fatal("bad --sim regular expression:", err)
+	if *listInventory {
+		if err := printInventory(inv, *simPattern); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *simPattern != "" {
+		if err := cfg.selectSimulators(inv, *simPattern); err != nil {
+			fatal(err)
+		}
 	}
-	if *simPattern != "" && len(simList) == 0 {
-		fatal("no simulators for pattern", *simPattern)
+	for _, sim := range cfg.Simulators {
+		if !inv.HasSimulator(sim.Name) {
+			fatal(fmt.Errorf("unknown simulator %q in config file", sim.Name))
+		}
+	}
+	simList := cfg.simulatorNames()
+	if cfg.Matrix && (*simDevMode || *simListOnly) {
+		log15.Warn("--matrix is ignored when using --dev or --sim.list mode")
 	}
-	if *simPattern != "" && *simDevMode {
+	if len(simList) > 0 && *simDevMode {
 		log15.Warn("--sim is ignored when using --dev mode")
 		simList = nil
 	}
@@ -73,16 +82,16 @@ func main() {
 	// Create the docker backends.
 	dockerConfig := &libdocker.Config{
 		Inventory:   inv,
-		PullEnabled: *dockerPull,
+		PullEnabled: cfg.Docker.Pull,
 	}
-	if *dockerNoCache != "" {
-		re, err := regexp.Compile(*dockerNoCache)
+	if cfg.Docker.NoCache != "" {
+		re, err := regexp.Compile(cfg.Docker.NoCache)
 		if err != nil {
 			fatal("bad --docker-nocache regular expression:", err)
 		}
 		dockerConfig.NoCachePattern = re
 	}
-	if *dockerOutput {
+	if cfg.Docker.Output {
 		dockerConfig.ContainerOutput = os.Stderr
 		dockerConfig.BuildOutput = os.Stderr
 	}
@@ -100,37 +109,121 @@ func main() {
 		cancel()
 	}()
 
+	// Set up the result store. Remote results are written to a local
+	// staging directory first.
+	var (
+		logDir      = cfg.ResultsRoot
+		stagingDir  string
+		resultStore logstore.Store
+	)
+	if logstore.IsRemote(cfg.ResultsRoot) {
+		if resultStore, err = logstore.Open(cfg.ResultsRoot); err != nil {
+			fatal("bad --results-root:", err)
+		}
+		if stagingDir, err = os.MkdirTemp("", "hive-results-"); err != nil {
+			fatal(err)
+		}
+		logDir = stagingDir
+		log15.Info("results are staged locally before upload", "dir", logDir, "store", resultStore)
+	}
+
+	// Store the effective configuration with the results.
+	var runConfigFile string
+	if !*simListOnly {
+		if runConfigFile, err = cfg.write(logDir); err != nil {
+			fatal("can't write run configuration:", err)
+		}
+	}
+
 	// Run.
-	env := libhive.SimEnv{
-		LogDir:             *testResultsRoot,
-		SimLogLevel:        *simLogLevel,
-		SimTestPattern:     *simTestPattern,
-		SimParallelism:     *simParallelism,
-		SimDurationLimit:   *simTimeLimit,
-		ClientStartTimeout: *clientTimeout,
+	baseEnv := libhive.SimEnv{
+		LogDir:        logDir,
+		ResultStore:   resultStore,
+		RunConfigFile: runConfigFile,
+		SimListOnly:   *simListOnly,
 	}
+	simEnv := func(sim string) libhive.SimEnv { return cfg.simEnv(baseEnv, sim) }
 	runner := libhive.NewRunner(inv, builder, cb)
-	clientList := splitAndTrim(*clients, ",")
+	clientList := cfg.clientDesignators()
+	uploadFailures := 0 // uploads outside of simulator runs
+	finishRun := func() {
+		if stagingDir != "" {
+			removeStagingDir(stagingDir, runner.UploadFailures()+uploadFailures)
+		}
+	}
 
 	if err := runner.Build(ctx, clientList, simList); err != nil {
+		finishRun()
 		fatal(err)
 	}
 
 	if *simDevMode {
-		runner.RunDevMode(ctx, env, *simDevModeAPIEndpoint)
+		runner.RunDevMode(ctx, simEnv(""), *simDevModeAPIEndpoint)
+		finishRun()
+		return
+	}
+
+	if *simListOnly {
+		listTests(ctx, runner, simList, simEnv)
+		finishRun()
+		return
+	}
+
+	if cfg.Matrix {
+		runs, err := runMatrix(ctx, runner, simList, simEnv, cfg.MatrixSize)
+		if err != nil {
+			finishRun()
+			fatal(err)
+		}
+		printMatrixSummary(os.Stdout, runs)
+		if file, err := writeMatrixSummary(logDir, runs); err != nil {
+			log15.Error("can't write matrix summary", "err", err)
+		} else if resultStore != nil {
+			if err := logstore.Upload(resultStore, logDir, file); err != nil {
+				log15.Error("could not upload matrix summary", "file", file, "store", resultStore, "err", err)
+				uploadFailures++
+			}
+		}
+		finishRun()
+		failCount, errCount := matrixFailures(runs)
+		if errCount > 0 {
+			fatal(fmt.Errorf("%d simulator runs failed", errCount))
+		}
+		exitWithFailures(failCount)
 		return
 	}
 
 	var failCount int
 	for _, sim := range simList {
-		result, err := runner.Run(ctx, sim, env)
+		result, err := runner.Run(ctx, sim, simEnv(sim))
 		if err != nil {
+			finishRun()
 			fatal(err)
 		}
+		if result.Skipped {
+			continue
+		}
 		failCount += result.TestsFailed
 		log15.Info(fmt.Sprintf("simulation %s finished", sim), "suites", result.Suites, "tests", result.Tests, "failed", result.TestsFailed)
 	}
+	finishRun()
+	exitWithFailures(failCount)
+}
+
+// removeStagingDir deletes the local staging directory of remote results. The directory
+// is kept when some files could not be uploaded.
+func removeStagingDir(dir string, uploadFailures int) {
+	if n := uploadFailures; n > 0 {
+		log15.Warn("some results were not uploaded, keeping local copy", "dir", dir, "failed", n)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log15.Warn("can't remove result staging directory", "dir", dir, "err", err)
+	}
+}
 
+// exitWithFailures exits with an error status if any tests failed.
+func exitWithFailures(failCount int) {
 	switch failCount {
 	case 0:
 	case 1:
@@ -140,6 +233,62 @@ func main() {
 	}
 }
 
+// simCatalog is the output of --sim.list.
+type simCatalog struct {
+	Simulator string                `json:"simulator"`
+	Suites    []simapi.CatalogSuite `json:"suites"`
+}
+
+// listTests runs the simulators in list-only mode and prints the test catalog.
+func listTests(ctx context.Context, runner *libhive.Runner, simList []string, simEnv func(string) libhive.SimEnv) {
+	output := make([]simCatalog, 0, len(simList))
+	for _, sim := range simList {
+		result, err := runner.Run(ctx, sim, simEnv(sim))
+		if err != nil {
+			fatal(err)
+		}
+		suites := result.Catalog
+		if suites == nil {
+			suites = []simapi.CatalogSuite{}
+		}
+		output = append(output, simCatalog{Simulator: sim, Suites: suites})
+		log15.Info(fmt.Sprintf("simulation %s listed", sim), "suites", len(suites))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(output); err != nil {
+		fatal(err)
+	}
+}
+
+// printInventory prints the clients and the simulators matching the pattern.
+func printInventory(inv libhive.Inventory, simPattern string) error {
+	sims := inv.SimulatorNames()
+	if simPattern != "" {
+		var err error
+		if sims, err = inv.MatchSimulators(simPattern); err != nil {
+			return fmt.Errorf("bad --sim regular expression: %v", err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT\tROLES")
+	for _, name := range inv.ClientNames() {
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(inv.Clients[name].Roles, ","))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "SIMULATOR\tROLES\tLIMIT\tTIMELIMIT\tDESCRIPTION")
+	for _, name := range sims {
+		meta := inv.Simulators[name]
+		timelimit := ""
+		if meta.TimeLimit != 0 {
+			timelimit = meta.TimeLimit.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, strings.Join(meta.Roles, ","), meta.TestPattern, timelimit, meta.Description)
+	}
+	return w.Flush()
+}
+
 func fatal(args ...interface{}) {
 	fmt.Fprintln(os.Stderr, args...)
 	os.Exit(1)
@@ -12,6 +12,21 @@ type TestResult struct {
 	Details string `json:"details"`
 }
 
+// CatalogSuite describes a test suite and its tests. It is reported to hive in
+// list-only mode.
+type CatalogSuite struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Tests       []CatalogTest `json:"tests"`
+}
+
+// CatalogTest describes a test case of a CatalogSuite.
+type CatalogTest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
 // ExecInfo is the result of running a command in a client container.
 type ExecInfo struct {
 	Stdout   string `json:"stdout"`
@@ -119,8 +119,19 @@
 
 	Both functions take a pointer to an instance of `Simulation` as well as a `Suite`.
 
+	Tests can run in parallel by calling `t.Parallel()` at the start of their `Run` function. The number of
+	concurrently running parallel tests is limited by the `HIVE_PARALLELISM` setting. `RunSuite()` waits for
+	all parallel tests to finish before ending the suite.
+
+	When hive is run with `--sim.list`, `HIVE_LIST_ONLY` is set and `RunSuite()` reports the suite and its
+	selected tests to hive without running them. Client tests are listed once for each available client.
+
 	To get an instance of `Simulation`, call the constructor function `New()`. This will look up the hive host
 	server URI and return an instance of `Simulation` that will be able to access the running hive host server.
 
+	Simulators can be configured using parameters given to hive with `--sim.param key=value`. Use
+	`sim.Param(name)` to read a parameter, or the typed helpers such as `sim.ParamInt(name, default)` and
+	`sim.ParamDuration(name, default)`, which return the default value when the parameter is not set.
+
 */
 package hivesim
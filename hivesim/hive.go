@@ -3,6 +3,7 @@ package hivesim
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,16 +13,93 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/hive/internal/simapi"
 )
 
+// Errors returned by Simulation methods. These correspond to error conditions
+// reported by the hive simulation API and can be checked using errors.Is.
+var (
+	ErrNoSuchNode           = errors.New("no such node")
+	ErrSuiteNotRunning      = errors.New("test suite not running")
+	ErrTestNotRunning       = errors.New("test not running")
+	ErrSuiteHasRunningTests = errors.New("test suite still has running tests")
+	ErrNoSuchNetwork        = errors.New("no such network")
+	ErrUnknownClient        = errors.New("unknown client type")
+	ErrClientStartFailed    = errors.New("client did not start")
+)
+
+var apiErrorCodes = map[string]error{
+	simapi.ErrCodeNoSuchNode:        ErrNoSuchNode,
+	simapi.ErrCodeSuiteNotRunning:   ErrSuiteNotRunning,
+	simapi.ErrCodeTestNotRunning:    ErrTestNotRunning,
+	simapi.ErrCodeSuiteHasTests:     ErrSuiteHasRunningTests,
+	simapi.ErrCodeNoSuchNetwork:     ErrNoSuchNetwork,
+	simapi.ErrCodeUnknownClient:     ErrUnknownClient,
+	simapi.ErrCodeClientStartFailed: ErrClientStartFailed,
+}
+
+// APIError is returned when the simulation API responds with an error status.
+// If the server reported a known error condition, APIError unwraps to one of
+// the Err* variables of this package.
+type APIError struct {
+	StatusCode int    // HTTP status code of the response
+	Code       string // error code reported by the server (may be empty)
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error corresponding to the error code.
+func (e *APIError) Unwrap() error {
+	return apiErrorCodes[e.Code]
+}
+
+const (
+	// These configure retries of API requests when the connection
+	// to the API server can't be established.
+	maxRequestRetries = 5
+	retryBaseDelay    = 200 * time.Millisecond
+)
+
+// defaultHTTPClient is used for API requests unless configured otherwise using
+// SetHTTPClient. It has no overall timeout because some API calls (e.g. starting
+// clients) can take a long time. Use the Context variants of API methods to
+// apply deadlines.
+var defaultHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
 // Simulation wraps the simulation HTTP API provided by hive.
 type Simulation struct {
-	url string
-	m   testMatcher
+	url    string
+	m      testMatcher
+	tags   tagMatcher
+	client *http.Client
+
+	// In list-only mode, tests are reported to the catalog instead of running.
+	listOnly bool
+
+	// Simulator parameters, set by --sim.param.
+	params map[string]string
+
+	// This limits the number of parallel tests.
+	parallelism int
+	limiter     chan struct{}
 }
 
 // New looks up the hive host URI using the HIVE_SIMULATOR environment variable
@@ -34,7 +112,7 @@ func New() *Simulation {
 	if url == "" {
 		panic("HIVE_SIMULATOR environment variable is empty")
 	}
-	sim := &Simulation{url: url}
+	sim := NewAt(url)
 	if p := os.Getenv("HIVE_TEST_PATTERN"); p != "" {
 		m, err := parseTestPattern(p)
 		if err != nil {
@@ -42,13 +120,66 @@ func New() *Simulation {
 		}
 		sim.m = m
 	}
+	if expr := os.Getenv("HIVE_TEST_TAGS"); expr != "" {
+		tags, err := parseTagExpr(expr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ignoring invalid test tags expression: "+err.Error())
+		}
+		sim.tags = tags
+	}
+	if p := os.Getenv("HIVE_LIST_ONLY"); p != "" && p != "0" && p != "false" {
+		sim.listOnly = true
+	}
+	if p := os.Getenv("HIVE_PARAMS"); p != "" {
+		params, err := parseParams(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ignoring simulator parameters: "+err.Error())
+		}
+		sim.params = params
+	}
+	if p := os.Getenv("HIVE_PARALLELISM"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid HIVE_PARALLELISM value %q\n", p)
+		} else {
+			sim.SetParallelism(n)
+		}
+	}
 	return sim
 }
 
 // NewAt creates a simulation connected to the given API endpoint. You'll will rarely need
 // to use this. In simulations launched by hive, use New() instead.
 func NewAt(url string) *Simulation {
-	return &Simulation{url: url}
+	sim := &Simulation{url: url, client: defaultHTTPClient}
+	sim.SetParallelism(1)
+	return sim
+}
+
+// SetHTTPClient sets the HTTP client used for API requests.
+func (sim *Simulation) SetHTTPClient(client *http.Client) {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	sim.client = client
+}
+
+// SetParallelism sets the maximum number of tests that can run in parallel. See
+// T.Parallel for more information. For simulator runs launched by hive, this is set
+// automatically in New(), from the --sim.parallelism flag.
+//
+// This must not be called while tests are running.
+func (sim *Simulation) SetParallelism(n int) {
+	if n < 1 {
+		n = 1
+	}
+	sim.parallelism = n
+	sim.limiter = make(chan struct{}, n)
+}
+
+// Parallelism returns the maximum number of parallel tests.
+func (sim *Simulation) Parallelism() int {
+	return sim.parallelism
 }
 
 // SetTestPattern sets the regular expression that enables/skips suites and test cases.
@@ -62,6 +193,34 @@ func (sim *Simulation) SetTestPattern(p string) {
 	sim.m = m
 }
 
+// SetTestTags sets the expression that selects test cases by their tags. This method is
+// provided for use in unit tests. For simulator runs launched by hive, the tag expression
+// is set automatically in New().
+func (sim *Simulation) SetTestTags(expr string) {
+	m, err := parseTagExpr(expr)
+	if err != nil {
+		panic("invalid test tags expression: " + err.Error())
+	}
+	sim.tags = m
+}
+
+// TestTags returns the expression used to select tests by their tags.
+func (sim *Simulation) TestTags() string {
+	return sim.tags.expr
+}
+
+// SetListOnly enables or disables list-only mode. In this mode, RunSuite reports the
+// suite and its tests to the catalog without running them. For simulator runs launched
+// by hive, this is set automatically in New(), from the --sim.list flag.
+func (sim *Simulation) SetListOnly(on bool) {
+	sim.listOnly = on
+}
+
+// ListOnly reports whether list-only mode is enabled.
+func (sim *Simulation) ListOnly() bool {
+	return sim.listOnly
+}
+
 // TestPattern returns the regular expressions used to enable/skip suite and test names.
 func (sim *Simulation) TestPattern() (suiteExpr string, testNameExpr string) {
 	se := ""
@@ -78,46 +237,98 @@ func (sim *Simulation) TestPattern() (suiteExpr string, testNameExpr string) {
 // EndTest finishes the test case, cleaning up everything, logging results, and returning
 // an error if the process could not be completed.
 func (sim *Simulation) EndTest(testSuite SuiteID, test TestID, testResult TestResult) error {
+	return sim.EndTestContext(context.Background(), testSuite, test, testResult)
+}
+
+// EndTestContext is like EndTest, with a context.
+func (sim *Simulation) EndTestContext(ctx context.Context, testSuite SuiteID, test TestID, testResult TestResult) error {
 	url := fmt.Sprintf("%s/testsuite/%d/test/%d", sim.url, testSuite, test)
-	return post(url, &testResult, nil)
+	return sim.post(ctx, url, &testResult, nil)
 }
 
 // StartSuite signals the start of a test suite.
 func (sim *Simulation) StartSuite(name, description, simlog string) (SuiteID, error) {
+	return sim.StartSuiteContext(context.Background(), name, description, simlog)
+}
+
+// StartSuiteContext is like StartSuite, with a context.
+func (sim *Simulation) StartSuiteContext(ctx context.Context, name, description, simlog string) (SuiteID, error) {
 	var (
 		url  = fmt.Sprintf("%s/testsuite", sim.url)
 		req  = &simapi.TestRequest{Name: name, Description: description}
 		resp SuiteID
 	)
-	err := post(url, req, &resp)
+	err := sim.post(ctx, url, req, &resp)
 	return resp, err
 }
 
+// AddCatalogSuite reports a test suite and its tests without running them.
+// This is used in list-only mode.
+func (sim *Simulation) AddCatalogSuite(suite CatalogSuite) error {
+	return sim.AddCatalogSuiteContext(context.Background(), suite)
+}
+
+// AddCatalogSuiteContext is like AddCatalogSuite, with a context.
+func (sim *Simulation) AddCatalogSuiteContext(ctx context.Context, suite CatalogSuite) error {
+	url := fmt.Sprintf("%s/catalog", sim.url)
+	req := &simapi.CatalogSuite{Name: suite.Name, Description: suite.Description, Tests: []simapi.CatalogTest{}}
+	for _, test := range suite.Tests {
+		req.Tests = append(req.Tests, simapi.CatalogTest(test))
+	}
+	return sim.post(ctx, url, req, nil)
+}
+
 // EndSuite signals the end of a test suite.
 func (sim *Simulation) EndSuite(testSuite SuiteID) error {
+	return sim.EndSuiteContext(context.Background(), testSuite)
+}
+
+// EndSuiteContext is like EndSuite, with a context.
+func (sim *Simulation) EndSuiteContext(ctx context.Context, testSuite SuiteID) error {
 	url := fmt.Sprintf("%s/testsuite/%d", sim.url, testSuite)
-	return requestDelete(url)
+	return sim.requestDelete(ctx, url)
 }
 
 // StartTest starts a new test case, returning the testcase id as a context identifier.
 func (sim *Simulation) StartTest(testSuite SuiteID, name string, description string) (TestID, error) {
+	return sim.StartSubtestContext(context.Background(), testSuite, 0, name, description)
+}
+
+// StartTestContext is like StartTest, with a context.
+func (sim *Simulation) StartTestContext(ctx context.Context, testSuite SuiteID, name string, description string) (TestID, error) {
+	return sim.StartSubtestContext(ctx, testSuite, 0, name, description)
+}
+
+// StartSubtest starts a new test case as a child of the parent test. The parent
+// relationship and tags are recorded in the test results.
+func (sim *Simulation) StartSubtest(testSuite SuiteID, parent TestID, name string, description string, tags ...string) (TestID, error) {
+	return sim.StartSubtestContext(context.Background(), testSuite, parent, name, description, tags...)
+}
+
+// StartSubtestContext is like StartSubtest, with a context.
+func (sim *Simulation) StartSubtestContext(ctx context.Context, testSuite SuiteID, parent TestID, name string, description string, tags ...string) (TestID, error) {
 	var (
 		url  = fmt.Sprintf("%s/testsuite/%d/test", sim.url, testSuite)
-		req  = &simapi.TestRequest{Name: name, Description: description}
+		req  = &simapi.TestRequest{Name: name, Description: description, Parent: uint32(parent), Tags: tags}
 		resp TestID
 	)
-	err := post(url, req, &resp)
+	err := sim.post(ctx, url, req, &resp)
 	return resp, err
 }
 
 // ClientTypes returns all client types available to this simulator run. This depends on
 // both the available client set and the command line filters.
 func (sim *Simulation) ClientTypes() ([]*ClientDefinition, error) {
+	return sim.ClientTypesContext(context.Background())
+}
+
+// ClientTypesContext is like ClientTypes, with a context.
+func (sim *Simulation) ClientTypesContext(ctx context.Context) ([]*ClientDefinition, error) {
 	var (
 		url  = fmt.Sprintf("%s/clients", sim.url)
 		resp []*ClientDefinition
 	)
-	err := get(url, &resp)
+	err := sim.get(ctx, url, &resp)
 	return resp, err
 }
 
@@ -136,6 +347,11 @@ func (sim *Simulation) StartClient(testSuite SuiteID, test TestID, parameters ma
 // StartClientWithOptions starts a new node (or other container) with specified options.
 // Returns container id and ip.
 func (sim *Simulation) StartClientWithOptions(testSuite SuiteID, test TestID, clientType string, options ...StartOption) (string, net.IP, error) {
+	return sim.StartClientContext(context.Background(), testSuite, test, clientType, options...)
+}
+
+// StartClientContext is like StartClientWithOptions, with a context.
+func (sim *Simulation) StartClientContext(ctx context.Context, testSuite SuiteID, test TestID, clientType string, options ...StartOption) (string, net.IP, error) {
 	var (
 		url  = fmt.Sprintf("%s/testsuite/%d/test/%d/node", sim.url, testSuite, test)
 		resp simapi.StartNodeResponse
@@ -152,7 +368,7 @@ func (sim *Simulation) StartClientWithOptions(testSuite SuiteID, test TestID, cl
 		opt.apply(setup)
 	}
 
-	err := setup.postWithFiles(url, &resp)
+	err := setup.postWithFiles(ctx, sim, url, &resp)
 	if err != nil {
 		return "", nil, err
 	}
@@ -165,12 +381,13 @@ func (sim *Simulation) StartClientWithOptions(testSuite SuiteID, test TestID, cl
 
 // StopClient signals to the host that the node is no longer required.
 func (sim *Simulation) StopClient(testSuite SuiteID, test TestID, nodeid string) error {
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/testsuite/%d/test/%d/node/%s", sim.url, testSuite, test, nodeid), nil)
-	if err != nil {
-		return err
-	}
-	_, err = http.DefaultClient.Do(req)
-	return err
+	return sim.StopClientContext(context.Background(), testSuite, test, nodeid)
+}
+
+// StopClientContext is like StopClient, with a context.
+func (sim *Simulation) StopClientContext(ctx context.Context, testSuite SuiteID, test TestID, nodeid string) error {
+	url := fmt.Sprintf("%s/testsuite/%d/test/%d/node/%s", sim.url, testSuite, test, nodeid)
+	return sim.requestDelete(ctx, url)
 }
 
 // ClientEnodeURL returns the enode URL of a running client.
@@ -178,9 +395,19 @@ func (sim *Simulation) ClientEnodeURL(testSuite SuiteID, test TestID, node strin
 	return sim.ClientEnodeURLNetwork(testSuite, test, node, "bridge")
 }
 
+// ClientEnodeURLContext is like ClientEnodeURL, with a context.
+func (sim *Simulation) ClientEnodeURLContext(ctx context.Context, testSuite SuiteID, test TestID, node string) (string, error) {
+	return sim.ClientEnodeURLNetworkContext(ctx, testSuite, test, node, "bridge")
+}
+
 // ClientEnodeURLCustomNetwork returns the enode URL of a running client in a custom network.
 func (sim *Simulation) ClientEnodeURLNetwork(testSuite SuiteID, test TestID, node string, network string) (string, error) {
-	resp, err := sim.ClientExec(testSuite, test, node, []string{"enode.sh"})
+	return sim.ClientEnodeURLNetworkContext(context.Background(), testSuite, test, node, network)
+}
+
+// ClientEnodeURLNetworkContext is like ClientEnodeURLNetwork, with a context.
+func (sim *Simulation) ClientEnodeURLNetworkContext(ctx context.Context, testSuite SuiteID, test TestID, node string, network string) (string, error) {
+	resp, err := sim.ClientExecContext(ctx, testSuite, test, node, []string{"enode.sh"})
 	if err != nil {
 		return "", err
 	}
@@ -206,7 +433,7 @@ func (sim *Simulation) ClientEnodeURLNetwork(testSuite SuiteID, test TestID, nod
 	}
 
 	// Get the actual IP for the container
-	ip, err := sim.ContainerNetworkIP(testSuite, network, node)
+	ip, err := sim.ContainerNetworkIPContext(ctx, testSuite, network, node)
 	if err != nil {
 		return "", err
 	}
@@ -218,106 +445,108 @@ func (sim *Simulation) ClientEnodeURLNetwork(testSuite SuiteID, test TestID, nod
 
 // ClientExec runs a command in a running client.
 func (sim *Simulation) ClientExec(testSuite SuiteID, test TestID, nodeid string, cmd []string) (*ExecInfo, error) {
+	return sim.ClientExecContext(context.Background(), testSuite, test, nodeid, cmd)
+}
+
+// ClientExecContext is like ClientExec, with a context.
+func (sim *Simulation) ClientExecContext(ctx context.Context, testSuite SuiteID, test TestID, nodeid string, cmd []string) (*ExecInfo, error) {
 	var (
 		url  = fmt.Sprintf("%s/testsuite/%d/test/%d/node/%s/exec", sim.url, testSuite, test, nodeid)
 		req  = &simapi.ExecRequest{Command: cmd}
 		resp *ExecInfo
 	)
-	err := post(url, req, &resp)
+	err := sim.post(ctx, url, req, &resp)
 	return resp, err
 }
 
 // CreateNetwork sends a request to the hive server to create a docker network by
 // the given name.
 func (sim *Simulation) CreateNetwork(testSuite SuiteID, networkName string) error {
+	return sim.CreateNetworkContext(context.Background(), testSuite, networkName)
+}
+
+// CreateNetworkContext is like CreateNetwork, with a context.
+func (sim *Simulation) CreateNetworkContext(ctx context.Context, testSuite SuiteID, networkName string) error {
 	url := fmt.Sprintf("%s/testsuite/%d/network/%s", sim.url, testSuite, networkName)
-	return post(url, nil, nil)
+	return sim.post(ctx, url, nil, nil)
 }
 
 // RemoveNetwork sends a request to the hive server to remove the given network.
 func (sim *Simulation) RemoveNetwork(testSuite SuiteID, network string) error {
+	return sim.RemoveNetworkContext(context.Background(), testSuite, network)
+}
+
+// RemoveNetworkContext is like RemoveNetwork, with a context.
+func (sim *Simulation) RemoveNetworkContext(ctx context.Context, testSuite SuiteID, network string) error {
 	url := fmt.Sprintf("%s/testsuite/%d/network/%s", sim.url, testSuite, network)
-	return requestDelete(url)
+	return sim.requestDelete(ctx, url)
 }
 
 // ConnectContainer sends a request to the hive server to connect the given
 // container to the given network.
 func (sim *Simulation) ConnectContainer(testSuite SuiteID, network, containerID string) error {
+	return sim.ConnectContainerContext(context.Background(), testSuite, network, containerID)
+}
+
+// ConnectContainerContext is like ConnectContainer, with a context.
+func (sim *Simulation) ConnectContainerContext(ctx context.Context, testSuite SuiteID, network, containerID string) error {
 	url := fmt.Sprintf("%s/testsuite/%d/network/%s/%s", sim.url, testSuite, network, containerID)
-	return post(url, nil, nil)
+	return sim.post(ctx, url, nil, nil)
 }
 
 // DisconnectContainer sends a request to the hive server to disconnect the given
 // container from the given network.
 func (sim *Simulation) DisconnectContainer(testSuite SuiteID, network, containerID string) error {
+	return sim.DisconnectContainerContext(context.Background(), testSuite, network, containerID)
+}
+
+// DisconnectContainerContext is like DisconnectContainer, with a context.
+func (sim *Simulation) DisconnectContainerContext(ctx context.Context, testSuite SuiteID, network, containerID string) error {
 	url := fmt.Sprintf("%s/testsuite/%d/network/%s/%s", sim.url, testSuite, network, containerID)
-	return requestDelete(url)
+	return sim.requestDelete(ctx, url)
 }
 
 // ContainerNetworkIP returns the IP address of a container on the given network. If the
 // container ID is "simulation", it returns the IP address of the simulator container.
 func (sim *Simulation) ContainerNetworkIP(testSuite SuiteID, network, containerID string) (string, error) {
+	return sim.ContainerNetworkIPContext(context.Background(), testSuite, network, containerID)
+}
+
+// ContainerNetworkIPContext is like ContainerNetworkIP, with a context.
+func (sim *Simulation) ContainerNetworkIPContext(ctx context.Context, testSuite SuiteID, network, containerID string) (string, error) {
 	var (
 		url  = fmt.Sprintf("%s/testsuite/%d/network/%s/%s", sim.url, testSuite, network, containerID)
 		resp string
 	)
-	err := get(url, &resp)
+	err := sim.get(ctx, url, &resp)
 	return resp, err
 }
 
-func (setup *clientSetup) postWithFiles(url string, result interface{}) error {
+func (setup *clientSetup) postWithFiles(ctx context.Context, sim *Simulation, url string, result interface{}) error {
 	var (
-		pipeR, pipeW = io.Pipe()
-		bufW         = bufio.NewWriter(pipeW)
-		pipeErrCh    = make(chan error, 1)
-		form         = multipart.NewWriter(bufW)
+		boundary  = multipart.NewWriter(io.Discard).Boundary()
+		pipeErrCh chan error
 	)
-
-	go func() (err error) {
-		defer func() { pipeErrCh <- err }()
-		defer pipeW.Close()
-
-		// Write 'config' parameter first.
-		fw, err := form.CreateFormField("config")
-		if err != nil {
-			return err
-		}
-		if err := json.NewEncoder(fw).Encode(&setup.config); err != nil {
-			return err
-		}
-
-		// Now upload the files.
-		for filename, open := range setup.files {
-			fw, err := form.CreateFormFile(filename, filepath.Base(filename))
-			if err != nil {
-				return err
-			}
-			fileReader, err := open()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: upload error for %s: %v\n", filename, err)
-				return err
-			}
-			_, copyErr := io.Copy(fw, fileReader)
-			fileReader.Close()
-			if copyErr != nil {
-				return copyErr
-			}
-		}
-
-		// Form must be closed or the request will be missing the terminating boundary.
-		if err := form.Close(); err != nil {
-			return err
-		}
-		return bufW.Flush()
-	}()
+	// newBody starts the uploader goroutine. This is also used as GetBody of the
+	// request, so the upload can be restarted when the request is retried.
+	newBody := func() (io.ReadCloser, error) {
+		pipeR, pipeW := io.Pipe()
+		errc := make(chan error, 1)
+		pipeErrCh = errc
+		go func() { errc <- setup.writeForm(pipeW, boundary) }()
+		return pipeR, nil
+	}
 
 	// Send the request.
-	req, err := http.NewRequest("POST", url, pipeR)
+	body, _ := newBody()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
+		body.Close()
 		return err
 	}
-	req.Header.Set("content-type", form.FormDataContentType())
-	httpErr := request(req, result)
+	req.GetBody = newBody
+	req.Header.Set("content-type", "multipart/form-data; boundary="+boundary)
+	httpErr := sim.request(req, result)
 
 	// Wait for the uploader goroutine to finish.
 	uploadErr := <-pipeErrCh
@@ -327,23 +556,67 @@ func (setup *clientSetup) postWithFiles(url string, result interface{}) error {
 	return httpErr
 }
 
-func get(url string, result interface{}) error {
-	httpReq, err := http.NewRequest("GET", url, nil)
+// writeForm writes the multipart form containing the client configuration and files.
+func (setup *clientSetup) writeForm(pipeW *io.PipeWriter, boundary string) (err error) {
+	defer func() { pipeW.CloseWithError(err) }()
+
+	bufW := bufio.NewWriter(pipeW)
+	form := multipart.NewWriter(bufW)
+	if err := form.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	// Write 'config' parameter first.
+	fw, err := form.CreateFormField("config")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(fw).Encode(&setup.config); err != nil {
+		return err
+	}
+
+	// Now upload the files.
+	for filename, open := range setup.files {
+		fw, err := form.CreateFormFile(filename, filepath.Base(filename))
+		if err != nil {
+			return err
+		}
+		fileReader, err := open()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: upload error for %s: %v\n", filename, err)
+			return err
+		}
+		_, copyErr := io.Copy(fw, fileReader)
+		fileReader.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+
+	// Form must be closed or the request will be missing the terminating boundary.
+	if err := form.Close(); err != nil {
+		return err
+	}
+	return bufW.Flush()
+}
+
+func (sim *Simulation) get(ctx context.Context, url string, result interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		panic(fmt.Errorf("can't create HTTP request: %v", err))
 	}
-	return request(httpReq, result)
+	return sim.request(httpReq, result)
 }
 
-func requestDelete(url string) error {
-	httpReq, err := http.NewRequest("DELETE", url, nil)
+func (sim *Simulation) requestDelete(ctx context.Context, url string) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
 		panic(fmt.Errorf("can't create HTTP request: %v", err))
 	}
-	return request(httpReq, nil)
+	return sim.request(httpReq, nil)
 }
 
-func post(url string, requestObj interface{}, result interface{}) error {
+func (sim *Simulation) post(ctx context.Context, url string, requestObj interface{}, result interface{}) error {
 	var reqBody []byte
 	if requestObj != nil {
 		var err error
@@ -352,7 +625,7 @@ func post(url string, requestObj interface{}, result interface{}) error {
 		}
 	}
 
-	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
 	if err != nil {
 		panic(fmt.Errorf("can't create HTTP request: %v", err))
 	}
@@ -362,11 +635,11 @@ func post(url string, requestObj interface{}, result interface{}) error {
 		}
 		httpReq.Header.Set("content-type", "application/json")
 	}
-	return request(httpReq, result)
+	return sim.request(httpReq, result)
 }
 
-func request(httpReq *http.Request, result interface{}) error {
-	resp, err := http.DefaultClient.Do(httpReq)
+func (sim *Simulation) request(httpReq *http.Request, result interface{}) error {
+	resp, err := sim.do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -382,7 +655,7 @@ func request(httpReq *http.Request, result interface{}) error {
 			if err := dec.Decode(&errobj); err != nil {
 				return fmt.Errorf("request failed (status %d) and can't decode error message: %v", resp.StatusCode, err)
 			}
-			return errors.New(errobj.Error)
+			return &APIError{StatusCode: resp.StatusCode, Code: errobj.Code, Message: errobj.Error}
 		default:
 			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
 			if len(respBody) == 0 {
@@ -403,3 +676,40 @@ func request(httpReq *http.Request, result interface{}) error {
 		return fmt.Errorf("invalid response status code %d", resp.StatusCode)
 	}
 }
+
+// do sends an HTTP request. When the connection to the API server can't be
+// established, the request is retried a few times.
+func (sim *Simulation) do(httpReq *http.Request) (*http.Response, error) {
+	ctx := httpReq.Context()
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := sim.client.Do(httpReq)
+		if err == nil || ctx.Err() != nil || attempt == maxRequestRetries || !isDialError(err) {
+			return resp, err
+		}
+		// The request body was consumed, it can only be retried if the body can be
+		// recreated.
+		if httpReq.Body != nil && httpReq.Body != http.NoBody && httpReq.GetBody == nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		httpReq = httpReq.Clone(ctx)
+		if httpReq.GetBody != nil {
+			if httpReq.Body, err = httpReq.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// isDialError reports whether err happened while connecting to the server.
+// This type of error is transient and the request can be retried safely.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
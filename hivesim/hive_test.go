@@ -1,15 +1,18 @@
 package hivesim
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/hive/internal/fakes"
@@ -340,6 +343,151 @@ func TestStartClientErrors(t *testing.T) {
 	}
 }
 
+// This test checks that API errors are reported as typed errors.
+func TestAPIErrors(t *testing.T) {
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	defer tm.Terminate()
+
+	sim := NewAt(srv.URL)
+	suiteID, err := sim.StartSuite("suite", "", "")
+	if err != nil {
+		t.Fatal("can't start suite:", err)
+	}
+	testID, err := sim.StartTest(suiteID, "test", "")
+	if err != nil {
+		t.Fatal("can't start test:", err)
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+		want error
+	}{
+		{
+			name: "StartTest with unknown suite",
+			fn:   func() error { _, err := sim.StartTest(suiteID+1, "test", ""); return err },
+			want: ErrSuiteNotRunning,
+		},
+		{
+			name: "StopClient with unknown node",
+			fn:   func() error { return sim.StopClient(suiteID, testID, "1234") },
+			want: ErrNoSuchNode,
+		},
+		{
+			name: "StartClient with unknown client",
+			fn: func() error {
+				_, _, err := sim.StartClientWithOptions(suiteID, testID, "unknown")
+				return err
+			},
+			want: ErrUnknownClient,
+		},
+		{
+			name: "RemoveNetwork with unknown network",
+			fn:   func() error { return sim.RemoveNetwork(suiteID, "network1") },
+			want: ErrNoSuchNetwork,
+		},
+		{
+			name: "EndSuite with running test",
+			fn:   func() error { return sim.EndSuite(suiteID) },
+			want: ErrSuiteHasRunningTests,
+		},
+		{
+			name: "EndTest twice",
+			fn: func() error {
+				if err := sim.EndTest(suiteID, testID, TestResult{Pass: true}); err != nil {
+					return err
+				}
+				return sim.EndTest(suiteID, testID, TestResult{Pass: true})
+			},
+			want: ErrTestNotRunning,
+		},
+	}
+	for _, test := range tests {
+		err := test.fn()
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: wrong error %v, want %v", test.name, err, test.want)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("%s: error is not *APIError", test.name)
+		}
+	}
+}
+
+// This test checks that requests are retried when the API server is not reachable.
+func TestRequestRetry(t *testing.T) {
+	// Find a free port for the server.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	// Start the server after a delay.
+	tm, srv := newFakeAPI(nil)
+	srv.Close()
+	defer tm.Terminate()
+	started := make(chan *httptest.Server, 1)
+	go func() {
+		time.Sleep(3 * retryBaseDelay / 2)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			started <- nil
+			return
+		}
+		srv := &httptest.Server{Listener: l, Config: &http.Server{Handler: tm.API()}}
+		srv.Start()
+		started <- srv
+	}()
+
+	sim := NewAt("http://" + addr)
+	_, err = sim.StartSuite("suite", "", "")
+	if srv := <-started; srv != nil {
+		defer srv.Close()
+	}
+	if err != nil {
+		t.Fatal("request was not retried:", err)
+	}
+}
+
+// This test checks that API requests can be canceled.
+func TestRequestContext(t *testing.T) {
+	release := make(chan struct{})
+	tm, srv := newFakeAPI(&fakes.BackendHooks{
+		RunProgram: func(containerID string, cmd []string) (*libhive.ExecInfo, error) {
+			<-release
+			return &libhive.ExecInfo{}, nil
+		},
+	})
+	defer srv.Close()
+	defer tm.Terminate()
+	defer close(release)
+
+	sim := NewAt(srv.URL)
+	suiteID, err := sim.StartSuite("suite", "", "")
+	if err != nil {
+		t.Fatal("can't start suite:", err)
+	}
+	testID, err := sim.StartTest(suiteID, "test", "")
+	if err != nil {
+		t.Fatal("can't start test:", err)
+	}
+	clientID, _, err := sim.StartClientWithOptions(suiteID, testID, "client-1")
+	if err != nil {
+		t.Fatal("can't start client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = sim.ClientExecContext(ctx, suiteID, testID, clientID, []string{"echo"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrong error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
 func TestStartClientInitialNetworks(t *testing.T) {
 	var (
 		connections = make(map[string]net.IP)
@@ -411,3 +559,43 @@ func newFakeAPI(hooks *fakes.BackendHooks) (*libhive.TestManager, *httptest.Serv
 	srv := httptest.NewServer(tm.API())
 	return tm, srv
 }
+
+// This test checks that simulator parameters are read from the environment.
+func TestParams(t *testing.T) {
+	t.Setenv("HIVE_SIMULATOR", "http://127.0.0.1:1")
+	t.Setenv("HIVE_PARAMS", `{"chainid":"0x385","slot-time":"6s","devnet":"true","name":"test"}`)
+	sim := New()
+
+	if v, ok := sim.Param("name"); !ok || v != "test" {
+		t.Errorf("wrong name param %q (set: %t)", v, ok)
+	}
+	if _, ok := sim.Param("missing"); ok {
+		t.Error("missing param reported as set")
+	}
+	if names := sim.ParamNames(); !reflect.DeepEqual(names, []string{"chainid", "devnet", "name", "slot-time"}) {
+		t.Errorf("wrong param names %v", names)
+	}
+	if v := sim.ParamUint64("chainid", 10); v != 901 {
+		t.Errorf("wrong chainid %d", v)
+	}
+	if v := sim.ParamDuration("slot-time", time.Second); v != 6*time.Second {
+		t.Errorf("wrong slot-time %v", v)
+	}
+	if v := sim.ParamBool("devnet", false); !v {
+		t.Error("wrong devnet value")
+	}
+	if v := sim.ParamInt("nodes", 4); v != 4 {
+		t.Errorf("wrong default value %d", v)
+	}
+	if v := sim.ParamString("missing", "default"); v != "default" {
+		t.Errorf("wrong default value %q", v)
+	}
+
+	// Invalid values panic.
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for invalid parameter value")
+		}
+	}()
+	sim.ParamInt("name", 0)
+}
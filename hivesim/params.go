@@ -0,0 +1,113 @@
+package hivesim
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// parseParams decodes the value of HIVE_PARAMS.
+func parseParams(enc string) (map[string]string, error) {
+	var params map[string]string
+	if err := json.Unmarshal([]byte(enc), &params); err != nil {
+		return nil, fmt.Errorf("invalid HIVE_PARAMS: %v", err)
+	}
+	return params, nil
+}
+
+// SetParam sets a simulator parameter. This method is provided for use in unit tests.
+// For simulator runs launched by hive, parameters are set automatically in New(), from
+// the --sim.param flag.
+func (sim *Simulation) SetParam(name, value string) {
+	if sim.params == nil {
+		sim.params = make(map[string]string)
+	}
+	sim.params[name] = value
+}
+
+// Param returns the value of a simulator parameter. The boolean result reports whether
+// the parameter is set.
+func (sim *Simulation) Param(name string) (string, bool) {
+	v, ok := sim.params[name]
+	return v, ok
+}
+
+// ParamNames returns the names of all parameters, sorted.
+func (sim *Simulation) ParamNames() []string {
+	names := make([]string, 0, len(sim.params))
+	for name := range sim.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParamString returns the value of a parameter, or def if it is not set.
+func (sim *Simulation) ParamString(name, def string) string {
+	if v, ok := sim.params[name]; ok {
+		return v
+	}
+	return def
+}
+
+// ParamInt returns the value of an integer parameter, or def if it is not set.
+// It panics if the parameter is not a valid integer.
+func (sim *Simulation) ParamInt(name string, def int) int {
+	v, ok := sim.params[name]
+	if !ok {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 0, 0)
+	if err != nil {
+		panic(paramError(name, v, "integer"))
+	}
+	return int(n)
+}
+
+// ParamUint64 returns the value of an unsigned integer parameter, or def if it is not
+// set. It panics if the parameter is not a valid unsigned integer.
+func (sim *Simulation) ParamUint64(name string, def uint64) uint64 {
+	v, ok := sim.params[name]
+	if !ok {
+		return def
+	}
+	n, err := strconv.ParseUint(v, 0, 64)
+	if err != nil {
+		panic(paramError(name, v, "unsigned integer"))
+	}
+	return n
+}
+
+// ParamBool returns the value of a boolean parameter, or def if it is not set.
+// It panics if the parameter is not a valid boolean.
+func (sim *Simulation) ParamBool(name string, def bool) bool {
+	v, ok := sim.params[name]
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		panic(paramError(name, v, "boolean"))
+	}
+	return b
+}
+
+// ParamDuration returns the value of a duration parameter (e.g. "12s"), or def if it is
+// not set. It panics if the parameter is not a valid duration.
+func (sim *Simulation) ParamDuration(name string, def time.Duration) time.Duration {
+	v, ok := sim.params[name]
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(paramError(name, v, "duration"))
+	}
+	return d
+}
+
+func paramError(name, value, kind string) string {
+	return fmt.Sprintf("simulator parameter %s: invalid %s %q", name, kind, value)
+}
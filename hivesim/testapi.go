@@ -29,7 +29,20 @@ func (s *Suite) Add(test AnyTest) *Suite {
 
 // AnyTest is a TestSpec or ClientTestSpec.
 type AnyTest interface {
-	runTest(*Simulation, SuiteID, *Suite) error
+	runTest(*Simulation, testGroup) error
+	listTests(*Simulation, testGroup) ([]testSpec, error)
+}
+
+// testGroup is the context in which tests are started. Top-level tests belong to the
+// suite, subtests belong to their parent test.
+type testGroup struct {
+	suiteID SuiteID
+	suite   *Suite
+	parent  TestID          // zero for top-level tests
+	parentT *T              // nil for top-level tests
+	tags    []string        // tags of the parent test, inherited by subtests
+	tagged  bool            // whether the parent test was selected by the tags
+	wg      *sync.WaitGroup // tracks parallel tests of the group
 }
 
 // Run executes all given test suites.
@@ -50,27 +63,56 @@ func MustRun(host *Simulation, suites ...Suite) {
 	}
 }
 
-// RunSuite runs all tests in a suite.
+// RunSuite runs all tests in a suite. It waits for all tests, including those
+// running in parallel, to complete before ending the suite.
+//
+// In list-only mode, the suite and its selected tests are reported to the catalog
+// instead. Subtests started by a running test are not listed.
 func RunSuite(host *Simulation, suite Suite) error {
 	if !host.m.match(suite.Name, "") {
 		fmt.Fprintf(os.Stderr, "skipping suite %q because it doesn't match test pattern %s\n", suite.Name, host.m.pattern)
 		return nil
 	}
+	if host.listOnly {
+		return listSuite(host, suite)
+	}
 
 	suiteID, err := host.StartSuite(suite.Name, suite.Description, "")
 	if err != nil {
 		return err
 	}
-	defer host.EndSuite(suiteID)
+	group := testGroup{suiteID: suiteID, suite: &suite, wg: new(sync.WaitGroup)}
+	defer func() {
+		group.wg.Wait()
+		host.EndSuite(suiteID)
+	}()
 
 	for _, test := range suite.Tests {
-		if err := test.runTest(host, suiteID, &suite); err != nil {
+		if err := test.runTest(host, group); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// listSuite reports the selected tests of a suite to the catalog.
+func listSuite(host *Simulation, suite Suite) error {
+	catalog := CatalogSuite{Name: suite.Name, Description: suite.Description}
+	group := testGroup{suite: &suite}
+	for _, test := range suite.Tests {
+		specs, err := test.listTests(host, group)
+		if err != nil {
+			return err
+		}
+		for _, spec := range specs {
+			if spec.selected(host) {
+				catalog.Tests = append(catalog.Tests, CatalogTest{Name: spec.name, Description: spec.desc, Tags: spec.tags})
+			}
+		}
+	}
+	return host.AddCatalogSuite(catalog)
+}
+
 // MustRunSuite runs the given suite, exiting the process if there is a problem reaching
 // the simulation API.
 func MustRunSuite(host *Simulation, suite Suite) {
@@ -97,9 +139,13 @@ type TestSpec struct {
 	Name        string
 	Description string
 
+	// Tags classify the test, e.g. "slow" or "fork:shanghai". They are recorded in the
+	// test results and can be used to select tests with the --sim.tags flag.
+	Tags []string
+
 	// If AlwaysRun is true, the test will run even if Name does not match the test
-	// pattern. This option is useful for tests that launch a client instance and
-	// then perform further tests against it.
+	// pattern or its tags are not selected. This option is useful for tests that
+	// launch a client instance and then perform further tests against it.
 	AlwaysRun bool
 
 	// The Run function is invoked when the test executes.
@@ -119,15 +165,24 @@ type ClientTestSpec struct {
 	Name        string
 	Description string
 
+	// Tags classify the test, e.g. "slow" or "fork:shanghai". They are recorded in the
+	// test results and can be used to select tests with the --sim.tags flag.
+	Tags []string
+
 	// If AlwaysRun is true, the test will run even if Name does not match the test
-	// pattern. This option is useful for tests that launch a client instance and
-	// then perform further tests against it.
+	// pattern or its tags are not selected. This option is useful for tests that
+	// launch a client instance and then perform further tests against it.
 	AlwaysRun bool
 
 	// This filters client types by role.
 	// If no role is specified, the test runs for all available client types.
 	Role string
 
+	// If Parallel is true, the test runs in parallel with other tests, as if it had
+	// called t.Parallel. The client is started once the test may run, so the number
+	// of running clients is limited by the parallelism setting.
+	Parallel bool
+
 	// Parameters and Files are launch options for client instances.
 	Parameters Params
 	Files      map[string]string
@@ -194,8 +249,19 @@ type T struct {
 	TestID  TestID
 	SuiteID SuiteID
 	suite   *Suite
+	tags    []string // including the tags of parent tests
+	tagged  bool     // selected by the tags, not just because of AlwaysRun
 	mu      sync.Mutex
 	result  TestResult
+
+	// These fields support parallel tests.
+	isParallel       bool
+	parallelSubtests bool            // set when a subtest calls Parallel
+	slotReleases     int             // number of subtest waits which gave up the slot
+	signal           chan struct{}   // closed when the test has finished or called Parallel
+	parent           *T              // nil for top-level tests
+	parentWG         *sync.WaitGroup // tracks parallel tests of the parent
+	subtests         sync.WaitGroup  // tracks parallel subtests
 }
 
 // StartClient starts a client instance. If the client cannot by started, the test fails immediately.
@@ -210,30 +276,57 @@ func (t *T) StartClient(clientType string, option ...StartOption) *Client {
 // RunClient runs the given client test against a single client type.
 // It waits for the subtest to complete.
 func (t *T) RunClient(clientType string, spec ClientTestSpec) {
-	test := testSpec{
-		suiteID:   t.SuiteID,
-		suite:     t.suite,
-		name:      clientTestName(spec.Name, clientType),
-		desc:      spec.Description,
-		alwaysRun: spec.AlwaysRun,
-	}
-	runTest(t.Sim, test, func(t *T) {
-		client := t.StartClient(clientType, spec.Parameters, WithStaticFiles(spec.Files))
-		spec.Run(t, client)
-	})
+	runTest(t.Sim, spec.testSpec(t.subtestGroup(), clientType), spec.runner(clientType))
 }
 
 // RunAllClients runs the given client test against all available client types.
 // It waits for all subtests to complete.
 func (t *T) RunAllClients(spec ClientTestSpec) {
-	spec.runTest(t.Sim, t.SuiteID, t.suite)
+	spec.runTest(t.Sim, t.subtestGroup())
 }
 
-// Run runs a subtest of this test. It waits for the subtest to complete before continuing.
-// It is safe to call this from multiple goroutines concurrently, just be sure to wait for
-// all your tests to finish until returning from the parent test.
+// Run runs a subtest of this test. It waits for the subtest to complete before
+// continuing, unless the subtest calls Parallel. It is safe to call this from multiple
+// goroutines concurrently, just be sure to wait for all your tests to finish until
+// returning from the parent test.
 func (t *T) Run(spec TestSpec) {
-	spec.runTest(t.Sim, t.SuiteID, t.suite)
+	spec.runTest(t.Sim, t.subtestGroup())
+}
+
+// subtestGroup returns the group of the test's subtests.
+func (t *T) subtestGroup() testGroup {
+	return testGroup{suiteID: t.SuiteID, suite: t.suite, parent: t.TestID, parentT: t, tags: t.tags, tagged: t.tagged, wg: &t.subtests}
+}
+
+// Parallel signals that this test is to be run in parallel with other tests. Calling
+// Parallel makes the caller of Run (or RunSuite) continue with the next test, while this
+// test waits until it can run.
+//
+// The number of tests running in parallel is limited by the simulation's parallelism
+// setting, which is configured by hive's --sim.parallelism flag. A test that runs
+// parallel subtests is only complete when all of its subtests have finished. While it
+// waits for them, it doesn't count towards the limit.
+//
+// Parallel must be called from the test's main goroutine, and should be called before
+// doing any work in the test. ClientTestSpec tests have already started their client when
+// the Run function is invoked, set ClientTestSpec.Parallel instead of calling Parallel.
+func (t *T) Parallel() {
+	t.mu.Lock()
+	if t.isParallel {
+		t.mu.Unlock()
+		panic("hivesim: t.Parallel called multiple times")
+	}
+	t.isParallel = true
+	t.mu.Unlock()
+	if t.parent != nil {
+		t.parent.mu.Lock()
+		t.parent.parallelSubtests = true
+		t.parent.mu.Unlock()
+	}
+
+	t.parentWG.Add(1)
+	close(t.signal)
+	t.Sim.limiter <- struct{}{}
 }
 
 // Error is like testing.T.Error.
@@ -301,77 +394,165 @@ func (t *T) FailNow() {
 }
 
 type testSpec struct {
-	suiteID   SuiteID
-	suite     *Suite
+	group     testGroup
 	name      string
 	desc      string
+	tags      []string
 	alwaysRun bool
 }
 
+// selected reports whether the test is selected by the test pattern and tags.
+func (test testSpec) selected(host *Simulation) bool {
+	if test.alwaysRun {
+		return true
+	}
+	return host.m.match(test.group.suite.Name, test.name) && test.tagsSelected(host)
+}
+
+// allTags returns the tags of the test, including those inherited from its parent.
+func (test testSpec) allTags() []string {
+	if len(test.group.tags) == 0 {
+		return test.tags
+	}
+	tags := append([]string{}, test.group.tags...)
+	for _, tag := range test.tags {
+		if !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (test testSpec) tagsSelected(host *Simulation) bool {
+	return host.tags.match(test.allTags(), test.group.tagged)
+}
+
 func runTest(host *Simulation, test testSpec, runit func(t *T)) error {
-	if !test.alwaysRun && !host.m.match(test.suite.Name, test.name) {
+	if !test.alwaysRun && !host.m.match(test.group.suite.Name, test.name) {
 		fmt.Fprintf(os.Stderr, "skipping test %q because it doesn't match test pattern %s\n", test.name, host.m.pattern)
 		return nil
 	}
+	if !test.alwaysRun && !test.tagsSelected(host) {
+		fmt.Fprintf(os.Stderr, "skipping test %q because its tags %v are not selected by %q\n", test.name, test.allTags(), host.tags.expr)
+		return nil
+	}
 
 	// Register test on simulation server and initialize the T.
 	t := &T{
-		Sim:     host,
-		SuiteID: test.suiteID,
-		suite:   test.suite,
+		Sim:      host,
+		SuiteID:  test.group.suiteID,
+		suite:    test.group.suite,
+		tags:     test.allTags(),
+		tagged:   test.tagsSelected(host),
+		signal:   make(chan struct{}),
+		parent:   test.group.parentT,
+		parentWG: test.group.wg,
 	}
-	testID, err := host.StartTest(test.suiteID, test.name, test.desc)
+	testID, err := host.StartSubtest(test.group.suiteID, test.group.parent, test.name, test.desc, t.tags...)
 	if err != nil {
 		return err
 	}
 	t.TestID = testID
 	t.result.Pass = true
+
+	// Run the test function. This waits until the test has finished,
+	// or until it has called t.Parallel().
+	go t.run(runit)
+	<-t.signal
+	return nil
+}
+
+// run executes the test function and reports the result.
+func (t *T) run(runit func(t *T)) {
 	defer func() {
+		if err := recover(); err != nil {
+			buf := make([]byte, 4096)
+			i := runtime.Stack(buf, false)
+			t.Logf("panic: %v\n\n%s", err, buf[:i])
+			t.Fail()
+		}
+
+		// The test function has returned. Parallel tests keep their slot until the
+		// test has ended and its clients are stopped.
+		t.waitSubtests()
 		t.mu.Lock()
-		defer t.mu.Unlock()
-		host.EndTest(test.suiteID, testID, t.result)
+		t.Sim.EndTest(t.SuiteID, t.TestID, t.result)
+		parallel := t.isParallel
+		t.mu.Unlock()
+		if parallel {
+			<-t.Sim.limiter
+			t.parentWG.Done()
+		} else {
+			close(t.signal)
+		}
 	}()
+	runit(t)
+}
 
-	// Run the test function.
-	done := make(chan struct{})
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				buf := make([]byte, 4096)
-				i := runtime.Stack(buf, false)
-				t.Logf("panic: %v\n\n%s", err, buf[:i])
-				t.Fail()
-			}
-			close(done)
-		}()
-		runit(t)
-	}()
-	<-done
+// waitSubtests waits for the parallel subtests of t. While waiting, the slot held by t or
+// its nearest parallel ancestor is given up, so the subtests can run.
+func (t *T) waitSubtests() {
+	t.mu.Lock()
+	parallelSubtests := t.parallelSubtests
+	t.mu.Unlock()
+	if !parallelSubtests {
+		return
+	}
+	holder := t.slotHolder()
+	if holder != nil {
+		holder.releaseSlot()
+	}
+	t.subtests.Wait()
+	if holder != nil {
+		holder.acquireSlot()
+	}
+}
+
+// slotHolder returns the nearest parallel test among t and its ancestors.
+func (t *T) slotHolder() *T {
+	for p := t; p != nil; p = p.parent {
+		p.mu.Lock()
+		parallel := p.isParallel
+		p.mu.Unlock()
+		if parallel {
+			return p
+		}
+	}
 	return nil
 }
 
-func (spec ClientTestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Suite) error {
-	clients, err := host.ClientTypes()
+// releaseSlot gives up the slot of a parallel test while a test waits for parallel
+// subtests. Multiple subtests may wait at the same time, the slot is released by the
+// first of them and taken back by the last.
+func (t *T) releaseSlot() {
+	t.mu.Lock()
+	t.slotReleases++
+	release := t.slotReleases == 1
+	t.mu.Unlock()
+	if release {
+		<-t.Sim.limiter
+	}
+}
+
+// acquireSlot takes back the slot given up by releaseSlot.
+func (t *T) acquireSlot() {
+	t.mu.Lock()
+	t.slotReleases--
+	acquire := t.slotReleases == 0
+	t.mu.Unlock()
+	if acquire {
+		t.Sim.limiter <- struct{}{}
+	}
+}
+
+func (spec ClientTestSpec) runTest(host *Simulation, group testGroup) error {
+	clients, err := spec.clientTypes(host)
 	if err != nil {
 		return err
 	}
 	for _, clientDef := range clients {
-		// 'role' is an optional filter, so eth1 tests, beacon node tests,
-		// validator tests, etc. can all live in harmony.
-		if spec.Role != "" && !clientDef.HasRole(spec.Role) {
-			continue
-		}
-		test := testSpec{
-			suiteID:   suiteID,
-			suite:     suite,
-			name:      clientTestName(spec.Name, clientDef.Name),
-			desc:      spec.Description,
-			alwaysRun: spec.AlwaysRun,
-		}
-		err := runTest(host, test, func(t *T) {
-			client := t.StartClient(clientDef.Name, spec.Parameters, WithStaticFiles(spec.Files))
-			spec.Run(t, client)
-		})
+		clientType := clientDef.Name
+		err := runTest(host, spec.testSpec(group, clientType), spec.runner(clientType))
 		if err != nil {
 			return err
 		}
@@ -379,6 +560,56 @@ func (spec ClientTestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Sui
 	return nil
 }
 
+// runner returns the test function, which starts the client and runs the test.
+func (spec ClientTestSpec) runner(clientType string) func(t *T) {
+	return func(t *T) {
+		if spec.Parallel {
+			t.Parallel()
+		}
+		client := t.StartClient(clientType, spec.Parameters, WithStaticFiles(spec.Files))
+		spec.Run(t, client)
+	}
+}
+
+func (spec ClientTestSpec) listTests(host *Simulation, group testGroup) ([]testSpec, error) {
+	clients, err := spec.clientTypes(host)
+	if err != nil {
+		return nil, err
+	}
+	var tests []testSpec
+	for _, clientDef := range clients {
+		tests = append(tests, spec.testSpec(group, clientDef.Name))
+	}
+	return tests, nil
+}
+
+// clientTypes returns the client types the test runs against.
+func (spec ClientTestSpec) clientTypes(host *Simulation) ([]*ClientDefinition, error) {
+	clients, err := host.ClientTypes()
+	if err != nil {
+		return nil, err
+	}
+	var result []*ClientDefinition
+	for _, clientDef := range clients {
+		// 'role' is an optional filter, so eth1 tests, beacon node tests,
+		// validator tests, etc. can all live in harmony.
+		if spec.Role == "" || clientDef.HasRole(spec.Role) {
+			result = append(result, clientDef)
+		}
+	}
+	return result, nil
+}
+
+func (spec ClientTestSpec) testSpec(group testGroup, clientType string) testSpec {
+	return testSpec{
+		group:     group,
+		name:      clientTestName(spec.Name, clientType),
+		desc:      spec.Description,
+		tags:      spec.Tags,
+		alwaysRun: spec.AlwaysRun,
+	}
+}
+
 // clientTestName ensures that 'name' contains the client type.
 func clientTestName(name, clientType string) string {
 	if name == "" {
@@ -390,13 +621,20 @@ func clientTestName(name, clientType string) string {
 	return name + " (" + clientType + ")"
 }
 
-func (spec TestSpec) runTest(host *Simulation, suiteID SuiteID, suite *Suite) error {
-	test := testSpec{
-		suiteID:   suiteID,
-		suite:     suite,
+func (spec TestSpec) runTest(host *Simulation, group testGroup) error {
+	return runTest(host, spec.testSpec(group), spec.Run)
+}
+
+func (spec TestSpec) listTests(host *Simulation, group testGroup) ([]testSpec, error) {
+	return []testSpec{spec.testSpec(group)}, nil
+}
+
+func (spec TestSpec) testSpec(group testGroup) testSpec {
+	return testSpec{
+		group:     group,
 		name:      spec.Name,
 		desc:      spec.Description,
+		tags:      spec.Tags,
 		alwaysRun: spec.AlwaysRun,
 	}
-	return runTest(host, test, spec.Run)
 }
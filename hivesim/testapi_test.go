@@ -1,13 +1,19 @@
 package hivesim
 
 import (
+	"fmt"
+	"net/http/httptest"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/ethereum/hive/internal/fakes"
 	"github.com/ethereum/hive/internal/libhive"
+	"github.com/ethereum/hive/internal/simapi"
 )
 
 // This test verifies that test errors are reported correctly through the API.
@@ -74,6 +80,221 @@ func TestSuiteReporting(t *testing.T) {
 	}
 }
 
+// This test checks that parallel tests are limited by the parallelism setting, and
+// that RunSuite waits for all of them.
+func TestParallel(t *testing.T) {
+	const parallelism = 3
+	var (
+		mu      sync.Mutex
+		running int
+		maxSeen int
+	)
+	parallelTest := func(t *T) {
+		t.Parallel()
+		mu.Lock()
+		running++
+		if running > maxSeen {
+			maxSeen = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}
+
+	suite := Suite{Name: "parallel suite"}
+	suite.Add(TestSpec{
+		Name: "parent",
+		Run: func(t *T) {
+			t.Parallel()
+			for i := 0; i < 5; i++ {
+				t.Run(TestSpec{Name: fmt.Sprintf("subtest-%d", i), Run: parallelTest})
+			}
+		},
+	})
+	for i := 0; i < 5; i++ {
+		suite.Add(TestSpec{Name: fmt.Sprintf("test-%d", i), Run: parallelTest})
+	}
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	sim := NewAt(srv.URL)
+	sim.SetParallelism(parallelism)
+	if err := RunSuite(sim, suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+
+	if maxSeen > parallelism {
+		t.Errorf("too many parallel tests: %d", maxSeen)
+	}
+	if maxSeen < 2 {
+		t.Errorf("tests did not run in parallel")
+	}
+
+	// Check that all tests were reported. The test IDs must be assigned in
+	// declaration order for the top-level tests.
+	results := tm.Results()
+	if len(results) != 1 {
+		t.Fatal("suite did not end")
+	}
+	cases := results[0].TestCases
+	if len(cases) != 11 {
+		t.Fatalf("wrong number of test cases %d", len(cases))
+	}
+	if cases[1].Name != "parent" {
+		t.Errorf("wrong name of first test %q", cases[1].Name)
+	}
+	for id, tc := range cases {
+		if tc.End.IsZero() {
+			t.Errorf("test %d (%s) did not end", id, tc.Name)
+		}
+	}
+}
+
+// This test checks that parallel subtests of a non-parallel subtest of a parallel test
+// can run. The waiting tests must give up their slot, or the suite never ends.
+func TestParallelNested(t *testing.T) {
+	suite := Suite{Name: "nested parallel"}
+	suite.Add(TestSpec{
+		Name: "A",
+		Run: func(t *T) {
+			t.Parallel()
+			t.Run(TestSpec{
+				Name: "B",
+				Run: func(t *T) {
+					for i := 0; i < 2; i++ {
+						t.Run(TestSpec{Name: fmt.Sprintf("C-%d", i), Run: func(t *T) { t.Parallel() }})
+					}
+				},
+			})
+			t.Log("B done")
+		},
+	})
+	suite.Add(TestSpec{Name: "D", Run: func(t *T) { t.Parallel() }})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	sim := NewAt(srv.URL)
+	sim.SetParallelism(1)
+	done := make(chan error, 1)
+	go func() { done <- RunSuite(sim, suite) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("suite run failed:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("suite run deadlocked")
+	}
+	cases := tm.Results()[0].TestCases
+	if len(cases) != 5 {
+		t.Fatalf("wrong number of test cases %d", len(cases))
+	}
+	for id, tc := range cases {
+		if tc.End.IsZero() {
+			t.Errorf("test %d (%s) did not end", id, tc.Name)
+		}
+	}
+}
+
+// This test checks that parallel client tests start their clients only when they may
+// run, so the number of running clients is limited by the parallelism setting.
+func TestParallelClients(t *testing.T) {
+	const parallelism = 2
+	var (
+		mu      sync.Mutex
+		running int
+		maxSeen int
+	)
+	tm, srv := newFakeAPI(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			running++
+			if running > maxSeen {
+				maxSeen = running
+			}
+			return &libhive.ContainerInfo{ID: containerID, IP: "192.0.2.1"}, nil
+		},
+		DeleteContainer: func(containerID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+			return nil
+		},
+	})
+	defer srv.Close()
+
+	suite := Suite{Name: "parallel clients"}
+	for i := 0; i < 4; i++ {
+		suite.Add(ClientTestSpec{
+			Name:     fmt.Sprintf("test-%d (CLIENT)", i),
+			Parallel: true,
+			Run: func(t *T, c *Client) {
+				time.Sleep(20 * time.Millisecond)
+			},
+		})
+	}
+	sim := NewAt(srv.URL)
+	sim.SetParallelism(parallelism)
+	if err := RunSuite(sim, suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+
+	if maxSeen > parallelism {
+		t.Errorf("too many running clients: %d", maxSeen)
+	}
+	if maxSeen < 2 {
+		t.Errorf("tests did not run in parallel")
+	}
+	if n := len(tm.Results()[0].TestCases); n != 8 {
+		t.Errorf("wrong number of test cases %d", n)
+	}
+}
+
+// This test checks that subtests are reported with their parent test ID.
+func TestSubtestParent(t *testing.T) {
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{
+		Name: "parent",
+		Run: func(t *T) {
+			t.Run(TestSpec{
+				Name: "child",
+				Run: func(t *T) {
+					t.Run(TestSpec{Name: "grandchild", Run: func(t *T) {}})
+				},
+			})
+		},
+	})
+	suite.Add(TestSpec{Name: "sibling", Run: func(t *T) {}})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	if err := RunSuite(NewAt(srv.URL), suite); err != nil {
+		t.Fatal("suite run failed:", err)
+	}
+
+	parents := make(map[string]string)
+	cases := tm.Results()[0].TestCases
+	for _, tc := range cases {
+		if tc.Parent != 0 {
+			parents[tc.Name] = cases[tc.Parent].Name
+		} else {
+			parents[tc.Name] = ""
+		}
+	}
+	want := map[string]string{
+		"parent":     "",
+		"child":      "parent",
+		"grandchild": "child",
+		"sibling":    "",
+	}
+	if !reflect.DeepEqual(parents, want) {
+		t.Fatalf("wrong test hierarchy: %v", parents)
+	}
+}
+
 // removeTimestamps removes test timestamps in results so they can be
 // compared using reflect.DeepEqual.
 func removeTimestamps(result map[libhive.TestSuiteID]*libhive.TestSuite) {
@@ -152,3 +373,183 @@ func TestSkipping(t *testing.T) {
 		}
 	}
 }
+
+var testTagsTests = []struct {
+	Tags    string
+	WantRun []string
+}{
+	{
+		Tags:    "",
+		WantRun: []string{"always", "fast", "slow", "slow-p2p", "untagged"},
+	},
+	{
+		Tags:    "p2p",
+		WantRun: []string{"always", "slow-p2p"},
+	},
+	{
+		Tags:    "!slow",
+		WantRun: []string{"always", "fast", "untagged"},
+	},
+	{
+		Tags:    "fast, p2p, -slow",
+		WantRun: []string{"always", "fast"},
+	},
+}
+
+// This test verifies that test cases are skipped when their tags are not selected.
+func TestSkippingTags(t *testing.T) {
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{Name: "untagged", Run: func(t *T) {}})
+	suite.Add(TestSpec{Name: "fast", Tags: []string{"fast"}, Run: func(t *T) {}})
+	suite.Add(TestSpec{Name: "slow", Tags: []string{"slow"}, Run: func(t *T) {}})
+	suite.Add(TestSpec{Name: "slow-p2p", Tags: []string{"slow", "p2p"}, Run: func(t *T) {}})
+	suite.Add(TestSpec{Name: "always", Tags: []string{"slow"}, Run: func(t *T) {}, AlwaysRun: true})
+
+	for _, test := range testTagsTests {
+		tm, srv := newFakeAPI(nil)
+		sim := NewAt(srv.URL)
+		sim.SetTestTags(test.Tags)
+		if err := RunSuite(sim, suite); err != nil {
+			t.Fatal("run failed:", err)
+		}
+		srv.Close()
+
+		var cases []string
+		for _, testCase := range tm.Results()[0].TestCases {
+			cases = append(cases, testCase.Name)
+			if testCase.Name == "slow-p2p" && !reflect.DeepEqual(testCase.Tags, []string{"slow", "p2p"}) {
+				t.Errorf("wrong tags reported: %v", testCase.Tags)
+			}
+		}
+		sort.Strings(cases)
+		if !reflect.DeepEqual(cases, test.WantRun) {
+			t.Errorf("tags %q: wrong executed test cases: %v", test.Tags, cases)
+		}
+	}
+}
+
+// This test checks that subtests inherit the tags of their parent test.
+func TestSubtestTags(t *testing.T) {
+	subtests := func(name string) func(t *T) {
+		return func(t *T) {
+			t.Run(TestSpec{Name: name + "/untagged", Run: func(t *T) {}})
+			t.Run(TestSpec{Name: name + "/slow", Tags: []string{"slow"}, Run: func(t *T) {}})
+		}
+	}
+	suite := Suite{Name: "suite"}
+	suite.Add(TestSpec{Name: "p2p", Tags: []string{"p2p"}, Run: subtests("p2p")})
+	suite.Add(TestSpec{Name: "always", AlwaysRun: true, Run: subtests("always")})
+	suite.Add(TestSpec{Name: "always-slow", Tags: []string{"slow"}, AlwaysRun: true, Run: subtests("always-slow")})
+
+	tests := []struct {
+		Tags    string
+		WantRun []string
+	}{
+		{
+			Tags:    "p2p",
+			WantRun: []string{"always", "always-slow", "p2p", "p2p/slow", "p2p/untagged"},
+		},
+		{
+			Tags:    "!slow",
+			WantRun: []string{"always", "always-slow", "always/untagged", "p2p", "p2p/untagged"},
+		},
+		{
+			Tags:    "p2p,!slow",
+			WantRun: []string{"always", "always-slow", "p2p", "p2p/untagged"},
+		},
+	}
+	for _, test := range tests {
+		tm, srv := newFakeAPI(nil)
+		sim := NewAt(srv.URL)
+		sim.SetTestTags(test.Tags)
+		if err := RunSuite(sim, suite); err != nil {
+			t.Fatal("run failed:", err)
+		}
+		srv.Close()
+
+		var cases []string
+		for _, testCase := range tm.Results()[0].TestCases {
+			cases = append(cases, testCase.Name)
+			if testCase.Name == "p2p/slow" && !reflect.DeepEqual(testCase.Tags, []string{"p2p", "slow"}) {
+				t.Errorf("tags %q: wrong tags reported for p2p/slow: %v", test.Tags, testCase.Tags)
+			}
+		}
+		sort.Strings(cases)
+		if !reflect.DeepEqual(cases, test.WantRun) {
+			t.Errorf("tags %q: wrong executed test cases: %v", test.Tags, cases)
+		}
+	}
+}
+
+// This test checks that list-only mode reports the selected tests without running them.
+func TestListOnly(t *testing.T) {
+	suite := Suite{Name: "suite", Description: "listed suite"}
+	suite.Add(TestSpec{Name: "plain", Description: "plain test", Tags: []string{"fast"}, Run: func(t *T) {
+		t.Fatal("test should not run")
+	}})
+	suite.Add(TestSpec{Name: "skipped", Run: func(t *T) {}})
+	suite.Add(ClientTestSpec{Name: "CLIENT test", Description: "client test", Role: "eth1", Run: func(t *T, c *Client) {
+		t.Fatal("test should not run")
+	}})
+	suite.Add(ClientTestSpec{Name: "all clients", Run: func(t *T, c *Client) {}})
+
+	tm, srv := newFakeAPI(nil)
+	defer srv.Close()
+	sim := NewAt(srv.URL)
+	sim.SetListOnly(true)
+	sim.SetTestPattern("suite/plain|test|all")
+	if err := RunSuite(sim, suite); err != nil {
+		t.Fatal("run failed:", err)
+	}
+
+	if len(tm.Results()) != 0 {
+		t.Fatal("suite was run:", spew.Sdump(tm.Results()))
+	}
+	want := []simapi.CatalogSuite{{
+		Name:        "suite",
+		Description: "listed suite",
+		Tests: []simapi.CatalogTest{
+			{Name: "plain", Description: "plain test", Tags: []string{"fast"}},
+			{Name: "client-1 test", Description: "client test"},
+			{Name: "all clients (client-1)"},
+			{Name: "all clients (client-2)"},
+		},
+	}}
+	if catalog := tm.Catalog(); !reflect.DeepEqual(catalog, want) {
+		t.Fatal("wrong catalog:", spew.Sdump(catalog))
+	}
+}
+
+// This test checks that the host rejects running tests in list-only mode, even when the
+// simulator ignores the mode.
+func TestListOnlyHost(t *testing.T) {
+	var started bool
+	backend := fakes.NewContainerBackend(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			started = true
+			return &libhive.ContainerInfo{ID: containerID, IP: "192.0.2.1"}, nil
+		},
+	})
+	defs := map[string]*libhive.ClientDefinition{"client-1": {Name: "client-1", Image: "client-1"}}
+	tm := libhive.NewTestManager(libhive.SimEnv{SimListOnly: true}, backend, defs)
+	srv := httptest.NewServer(tm.API())
+	defer srv.Close()
+	sim := NewAt(srv.URL)
+
+	if _, err := sim.StartSuite("suite", "", ""); err == nil || !strings.Contains(err.Error(), "list-only") {
+		t.Errorf("wrong error from StartSuite: %v", err)
+	}
+	if _, err := sim.StartTest(0, "test", ""); err == nil || !strings.Contains(err.Error(), "list-only") {
+		t.Errorf("wrong error from StartTest: %v", err)
+	}
+	if err := sim.CreateNetwork(0, "net"); err == nil || !strings.Contains(err.Error(), "list-only") {
+		t.Errorf("wrong error from CreateNetwork: %v", err)
+	}
+	_, _, err := sim.StartClient(0, 1, map[string]string{"CLIENT": "client-1"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "list-only") {
+		t.Errorf("wrong error from StartClient: %v", err)
+	}
+	if started {
+		t.Error("client container was started")
+	}
+}
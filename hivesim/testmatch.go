@@ -1,6 +1,7 @@
 package hivesim
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -75,3 +76,64 @@ func splitRegexp(s string) []string {
 	}
 	return append(a, s)
 }
+
+// tagMatcher selects tests by their tags.
+type tagMatcher struct {
+	include []string
+	exclude []string
+	expr    string
+}
+
+// parseTagExpr parses a tag selection expression. The expression is a comma-separated
+// list of tags. Tags prefixed by '!' or '-' exclude tests. If the expression contains
+// any other tags, only tests having at least one of them are selected.
+func parseTagExpr(expr string) (m tagMatcher, err error) {
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		exclude := strings.HasPrefix(term, "!") || strings.HasPrefix(term, "-")
+		if exclude {
+			term = term[1:]
+		}
+		if term == "" || strings.ContainsAny(term, " \t!") {
+			return tagMatcher{}, fmt.Errorf("invalid tag %q in expression", term)
+		}
+		if exclude {
+			m.exclude = append(m.exclude, term)
+		} else {
+			m.include = append(m.include, term)
+		}
+	}
+	m.expr = expr
+	return m, nil
+}
+
+// match checks whether the given tags are selected. When parentSelected is true, the
+// test's parent was selected by the tags, and the test runs unless its tags are excluded.
+func (m *tagMatcher) match(tags []string, parentSelected bool) bool {
+	for _, tag := range tags {
+		if containsTag(m.exclude, tag) {
+			return false
+		}
+	}
+	if len(m.include) == 0 || parentSelected {
+		return true
+	}
+	for _, tag := range tags {
+		if containsTag(m.include, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(list []string, tag string) bool {
+	for _, t := range list {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -9,10 +9,9 @@ import (
 
 // BuilderHooks can be used to override the behavior of the fake builder.
 type BuilderHooks struct {
-	BuildClientImage    func(context.Context, string) (string, error)
+	BuildClientImage    func(context.Context, libhive.ClientDesignator) (string, error)
 	BuildSimulatorImage func(context.Context, string) (string, error)
 	ReadFile            func(ctx context.Context, image string, file string) ([]byte, error)
-	ReadClientMetadata  func(name string) (*libhive.ClientMetadata, error)
 }
 
 // fakeBuilder implements Backend without docker.
@@ -29,11 +28,11 @@ func NewBuilder(hooks *BuilderHooks) libhive.Builder {
 	return b
 }
 
-func (b *fakeBuilder) BuildClientImage(ctx context.Context, client string) (string, error) {
+func (b *fakeBuilder) BuildClientImage(ctx context.Context, client libhive.ClientDesignator) (string, error) {
 	if b.hooks.BuildClientImage != nil {
 		return b.hooks.BuildClientImage(ctx, client)
 	}
-	return "fakebuild/client/" + client + ":latest", nil
+	return "fakebuild/client/" + client.Name() + ":latest", nil
 }
 
 func (b *fakeBuilder) BuildSimulatorImage(ctx context.Context, sim string) (string, error) {
@@ -47,14 +46,6 @@ func (b *fakeBuilder) BuildImage(ctx context.Context, name string, fsys fs.FS) e
 	return nil
 }
 
-func (b *fakeBuilder) ReadClientMetadata(name string) (*libhive.ClientMetadata, error) {
-	if b.hooks.ReadClientMetadata != nil {
-		return b.hooks.ReadClientMetadata(name)
-	}
-	m := libhive.ClientMetadata{Roles: []string{"eth1"}}
-	return &m, nil
-}
-
 func (b *fakeBuilder) ReadFile(ctx context.Context, image, file string) ([]byte, error) {
 	if b.hooks.ReadFile != nil {
 		return b.hooks.ReadFile(ctx, image, file)
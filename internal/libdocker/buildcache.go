@@ -0,0 +1,117 @@
+package libdocker
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"sort"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// buildHashLabel is the image label containing the hash of the image's build inputs.
+const buildHashLabel = "hive.build.hash"
+
+// buildHash computes a hash of all inputs of an image build: the files of the build
+// context, the Dockerfile name and the build arguments. Like docker, it leaves out
+// files matching the .dockerignore of the context, except for the Dockerfile and
+// .dockerignore itself.
+func buildHash(fsys fs.FS, dockerfile string, args []docker.BuildArg) (string, error) {
+	ignore, err := ignorePatterns(fsys, "build context")
+	if err != nil {
+		return "", err
+	}
+	h := newBuildHash(dockerfile, args)
+	err = fs.WalkDir(fsys, ".", func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != "." && path != dockerfile && path != ".dockerignore" {
+			skip, err := ignore.Matches(path)
+			if err != nil {
+				return err
+			}
+			if skip {
+				// Excluded directories can only be skipped entirely if no pattern
+				// re-includes files in them.
+				if e.IsDir() && !ignore.Exclusions() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		writeHashString(h, path)
+		writeHashString(h, info.Mode().String())
+		if !e.Type().IsRegular() {
+			return nil
+		}
+		file, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writeHashString(h, fmt.Sprint(info.Size()))
+		_, err = io.Copy(h, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// addDependencyHash combines a build hash with the IDs of images used by the build.
+// Builds without dependencies keep their hash.
+func addDependencyHash(hash string, deps []string) string {
+	if len(deps) == 0 {
+		return hash
+	}
+	h := sha256.New()
+	writeHashString(h, hash)
+	for _, id := range deps {
+		writeHashString(h, id)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newBuildHash creates a hash of the Dockerfile name and build arguments. The caller
+// adds the build context.
+func newBuildHash(dockerfile string, args []docker.BuildArg) hash.Hash {
+	h := sha256.New()
+	writeHashString(h, "hive-build-v1")
+	writeHashString(h, dockerfile)
+
+	args = append([]docker.BuildArg{}, args...)
+	sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+	for _, arg := range args {
+		writeHashString(h, arg.Name)
+		writeHashString(h, arg.Value)
+	}
+	return h
+}
+
+// writeHashString writes a length-prefixed string to h.
+func writeHashString(h hash.Hash, s string) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(s)))
+	h.Write(n[:])
+	h.Write([]byte(s))
+}
+
+// imageUpToDate reports whether the image exists and was built from inputs with the
+// given hash.
+func (b *Builder) imageUpToDate(image, hash string) bool {
+	img, err := b.client.InspectImage(image)
+	if err != nil || img.Config == nil {
+		return false
+	}
+	return img.Config.Labels[buildHashLabel] == hash
+}
@@ -0,0 +1,95 @@
+package libdocker
+
+import (
+	"testing"
+	"testing/fstest"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func buildContext() fstest.MapFS {
+	return fstest.MapFS{
+		"Dockerfile":      {Data: []byte("FROM alpine\n")},
+		".dockerignore":   {Data: []byte("# comment\n/workspace\nhive\n*.log\n!keep.log\n")},
+		"main.go":         {Data: []byte("package main\n")},
+		"keep.log":        {Data: []byte("kept")},
+		"debug.log":       {Data: []byte("ignored")},
+		"hive":            {Data: []byte("binary")},
+		"workspace/state": {Data: []byte("state")},
+		".git/HEAD":       {Data: []byte("ref: refs/heads/master\n")},
+	}
+}
+
+func TestBuildHash(t *testing.T) {
+	base, err := buildHash(buildContext(), "Dockerfile", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(fstest.MapFS)
+		args    []docker.BuildArg
+		changed bool
+	}{
+		{name: "unchanged", change: func(fstest.MapFS) {}},
+		{name: "source file", change: func(fs fstest.MapFS) { fs["main.go"].Data = []byte("package other\n") }, changed: true},
+		{name: "new file", change: func(fs fstest.MapFS) { fs["new.go"] = &fstest.MapFile{} }, changed: true},
+		{name: "Dockerfile", change: func(fs fstest.MapFS) { fs["Dockerfile"].Data = []byte("FROM debian\n") }, changed: true},
+		{name: "re-included file", change: func(fs fstest.MapFS) { fs["keep.log"].Data = []byte("changed") }, changed: true},
+		{name: "dockerignore", change: func(fs fstest.MapFS) { fs[".dockerignore"].Data = []byte("hive\n") }, changed: true},
+		{name: "build arg", change: func(fstest.MapFS) {}, args: []docker.BuildArg{{Name: "branch", Value: "master"}}, changed: true},
+		{name: "ignored file", change: func(fs fstest.MapFS) { fs["debug.log"].Data = []byte("changed") }},
+		{name: "ignored binary", change: func(fs fstest.MapFS) { fs["hive"].Data = []byte("rebuilt") }},
+		{name: "ignored directory", change: func(fs fstest.MapFS) { fs["workspace/new"] = &fstest.MapFile{Data: []byte("new")} }},
+		{name: "git directory", change: func(fs fstest.MapFS) { fs[".git/HEAD"].Data = []byte("ref: refs/heads/other\n") }},
+	}
+	for _, test := range tests {
+		fsys := buildContext()
+		test.change(fsys)
+		hash, err := buildHash(fsys, "Dockerfile", test.args)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if changed := hash != base; changed != test.changed {
+			t.Errorf("%s: hash changed = %t, want %t", test.name, changed, test.changed)
+		}
+	}
+}
+
+func TestBuildHashDockerfileIgnored(t *testing.T) {
+	// The Dockerfile is always part of the build, even when .dockerignore matches it.
+	fsys := fstest.MapFS{
+		"Dockerfile":    {Data: []byte("FROM alpine\n")},
+		".dockerignore": {Data: []byte("Dockerfile\n")},
+	}
+	base, err := buildHash(fsys, "Dockerfile", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys["Dockerfile"].Data = []byte("FROM debian\n")
+	hash, err := buildHash(fsys, "Dockerfile", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == base {
+		t.Error("hash didn't change when ignored Dockerfile changed")
+	}
+}
+
+func TestAddDependencyHash(t *testing.T) {
+	const hash = "0123"
+	if h := addDependencyHash(hash, nil); h != hash {
+		t.Errorf("hash without dependencies changed to %s", h)
+	}
+	a := addDependencyHash(hash, []string{"sha256:aa", "sha256:bb"})
+	if a == hash {
+		t.Error("dependencies don't change the hash")
+	}
+	if b := addDependencyHash(hash, []string{"sha256:aa", "sha256:bb"}); b != a {
+		t.Error("hash isn't deterministic")
+	}
+	if b := addDependencyHash(hash, []string{"sha256:aa", "sha256:cc"}); b == a {
+		t.Error("changed dependency doesn't change the hash")
+	}
+}
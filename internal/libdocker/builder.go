@@ -10,12 +10,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 
 	"github.com/ethereum/hive/internal/libhive"
 	docker "github.com/fsouza/go-dockerclient"
 	"gopkg.in/inconshreveable/log15.v2"
-	"gopkg.in/yaml.v3"
 )
 
 // Builder takes care of building docker images.
@@ -33,43 +32,53 @@ func NewBuilder(client *docker.Client, cfg *Config) *Builder {
 	return b
 }
 
-// ReadClientMetadata reads metadata of the given client.
-func (b *Builder) ReadClientMetadata(name string) (*libhive.ClientMetadata, error) {
-	dir := b.config.Inventory.ClientDirectory(name)
-	f, err := os.Open(filepath.Join(dir, "hive.yaml"))
+// BuildClientImage builds a docker image of the given client.
+func (b *Builder) BuildClientImage(ctx context.Context, client libhive.ClientDesignator) (string, error) {
+	dir := b.config.Inventory.ClientDirectory(client.Client)
+	variant := client.Dockerfile
+	if variant == "" && client.Local != "" {
+		variant = "local"
+	}
+	dockerfile, err := b.config.Inventory.ClientDockerfile(client.Client, variant)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Eth1 client by default.
-			return &libhive.ClientMetadata{Roles: []string{"eth1"}}, nil
-		} else {
-			return nil, fmt.Errorf("failed to read hive metadata file in '%s': %v", dir, err)
-		}
+		b.logger.Error("can't build client image", "client", client.Name(), "err", err)
+		return "", err
 	}
-	defer f.Close()
-	var out libhive.ClientMetadata
-	if err := yaml.NewDecoder(f).Decode(&out); err != nil {
-		return nil, fmt.Errorf("failed to decode hive metadata file in '%s': %v", dir, err)
+	tag := fmt.Sprintf("hive/clients/%s:latest", client.Name())
+	if client.Local != "" {
+		err = b.buildLocalImage(ctx, dir, client.Local, dockerfile, client.BuildArgs, tag)
+	} else {
+		err = b.buildImage(ctx, dir, dockerfile, client.BuildArgs, nil, tag)
 	}
-	return &out, nil
-}
-
-// BuildClientImage builds a docker image of the given client.
-func (b *Builder) BuildClientImage(ctx context.Context, name string) (string, error) {
-	dir := b.config.Inventory.ClientDirectory(name)
-	_, branch := libhive.SplitClientName(name)
-	tag := fmt.Sprintf("hive/clients/%s:latest", name)
-	err := b.buildImage(ctx, dir, "Dockerfile", branch, tag)
 	return tag, err
 }
 
-// BuildSimulatorImage builds a docker image of a simulator.
+// BuildSimulatorImage builds a docker image of a simulator. Helper images of the
+// simulator are built first.
 func (b *Builder) BuildSimulatorImage(ctx context.Context, name string) (string, error) {
 	dir := b.config.Inventory.SimulatorDirectory(name)
+	meta := b.config.Inventory.Simulators[name]
+
+	// Build the helper images first. The simulator image must be rebuilt when any of
+	// them changes, so their IDs are part of the simulator's build hash.
+	var helperIDs []string
+	for _, img := range meta.HelperImages {
+		tag := fmt.Sprintf("hive/simulators/%s/%s:latest", name, img.Name)
+		if err := b.buildImage(ctx, filepath.Join(dir, filepath.FromSlash(img.Dir)), "Dockerfile", nil, nil, tag); err != nil {
+			return "", err
+		}
+		helper, err := b.client.InspectImage(tag)
+		if err != nil {
+			return "", fmt.Errorf("can't inspect helper image %s: %v", tag, err)
+		}
+		helperIDs = append(helperIDs, helper.ID)
+	}
+
 	buildContextPath := dir
 	buildDockerfile := "Dockerfile"
-	// build context dir of simulator can be overridden with "context.txt" file containing the desired build path
-	if contextPathBytes, err := ioutil.ReadFile(filepath.Join(filepath.FromSlash(dir), "context.txt")); err == nil {
-		buildContextPath = filepath.Join(dir, strings.TrimSpace(string(contextPathBytes)))
+	// The build context of the simulator can be overridden in hive.yaml.
+	if meta.BuildContext != "" {
+		buildContextPath = filepath.Join(dir, filepath.FromSlash(meta.BuildContext))
 		if p, err := filepath.Rel(buildContextPath, filepath.Join(filepath.FromSlash(dir), "Dockerfile")); err != nil {
 			return "", fmt.Errorf("failed to derive relative simulator Dockerfile path: %v", err)
 		} else {
@@ -77,7 +86,7 @@ func (b *Builder) BuildSimulatorImage(ctx context.Context, name string) (string,
 		}
 	}
 	tag := fmt.Sprintf("hive/simulators/%s:latest", name)
-	err := b.buildImage(ctx, buildContextPath, buildDockerfile, "", tag)
+	err := b.buildImage(ctx, buildContextPath, buildDockerfile, nil, helperIDs, tag)
 	return tag, err
 }
 
@@ -89,6 +98,15 @@ func (b *Builder) BuildImage(ctx context.Context, name string, fsys fs.FS) error
 		nocache = b.config.NoCachePattern.MatchString(name)
 	}
 
+	hash, err := buildHash(fsys, "Dockerfile", nil)
+	if err != nil {
+		return err
+	}
+	if !nocache && !b.config.PullEnabled && b.imageUpToDate(name, hash) {
+		b.logger.Info("image is up to date", "image", name)
+		return nil
+	}
+
 	pipeR, pipeW := io.Pipe()
 	go b.archiveFS(ctx, pipeW, fsys)
 
@@ -99,6 +117,7 @@ func (b *Builder) BuildImage(ctx context.Context, name string, fsys fs.FS) error
 		OutputStream: ioutil.Discard,
 		NoCache:      nocache,
 		Pull:         b.config.PullEnabled,
+		Labels:       map[string]string{buildHashLabel: hash},
 	}
 	if b.config.BuildOutput != nil {
 		opts.OutputStream = b.config.BuildOutput
@@ -213,23 +232,51 @@ func (b *Builder) ReadFile(ctx context.Context, image, path string) ([]byte, err
 }
 
 // buildImage builds a single docker image from the specified context.
-// branch specifes a build argument to use a specific base image branch or github source branch.
-func (b *Builder) buildImage(ctx context.Context, contextDir, dockerFile, branch, imageTag string) error {
-	nocache := false
-	if b.config.NoCachePattern != nil {
-		nocache = b.config.NoCachePattern.MatchString(imageTag)
-	}
-
+// buildArgs are passed to the Dockerfile, e.g. 'branch' to select a specific base image
+// branch or github source branch. deps are the IDs of images used by the build, the
+// image is rebuilt when they change.
+func (b *Builder) buildImage(ctx context.Context, contextDir, dockerFile string, buildArgs map[string]string, deps []string, imageTag string) error {
 	logger := b.logger.New("image", imageTag)
 	context, err := filepath.Abs(contextDir)
 	if err != nil {
 		logger.Error("can't find path to context directory", "err", err)
 		return err
 	}
+	opts, logctx := b.buildOptions(ctx, dockerFile, buildArgs, imageTag)
+	opts.ContextDir = context
+	logctx = append([]interface{}{"dir", contextDir}, logctx...)
+
+	// Skip the build if the image was already built from the same inputs.
+	hash, err := buildHash(os.DirFS(context), dockerFile, opts.BuildArgs)
+	if err != nil {
+		logger.Error("can't hash build context", "err", err)
+		return err
+	}
+	hash = addDependencyHash(hash, deps)
+	if !opts.NoCache && !opts.Pull && b.imageUpToDate(imageTag, hash) {
+		logger.Info("image is up to date", logctx...)
+		return nil
+	}
+	opts.Labels = map[string]string{buildHashLabel: hash}
+
+	logger.Info("building image", logctx...)
+	if err := b.client.BuildImage(opts); err != nil {
+		logger.Error("image build failed", "err", err)
+		return err
+	}
+	return nil
+}
+
+// buildOptions creates the options of an image build, without the build context. It also
+// returns the settings for logging.
+func (b *Builder) buildOptions(ctx context.Context, dockerFile string, buildArgs map[string]string, imageTag string) (docker.BuildImageOptions, []interface{}) {
+	nocache := false
+	if b.config.NoCachePattern != nil {
+		nocache = b.config.NoCachePattern.MatchString(imageTag)
+	}
 	opts := docker.BuildImageOptions{
 		Context:      ctx,
 		Name:         imageTag,
-		ContextDir:   context,
 		OutputStream: ioutil.Discard,
 		Dockerfile:   dockerFile,
 		NoCache:      nocache,
@@ -238,16 +285,18 @@ func (b *Builder) buildImage(ctx context.Context, contextDir, dockerFile, branch
 	if b.config.BuildOutput != nil {
 		opts.OutputStream = b.config.BuildOutput
 	}
-	logctx := []interface{}{"dir", contextDir, "nocache", opts.NoCache, "pull", opts.Pull}
-	if branch != "" {
-		logctx = append(logctx, "branch", branch)
-		opts.BuildArgs = []docker.BuildArg{{Name: "branch", Value: branch}}
+	logctx := []interface{}{"nocache", opts.NoCache, "pull", opts.Pull}
+	if dockerFile != "Dockerfile" {
+		logctx = append(logctx, "dockerfile", dockerFile)
 	}
-
-	logger.Info("building image", logctx...)
-	if err := b.client.BuildImage(opts); err != nil {
-		logger.Error("image build failed", "err", err)
-		return err
+	argNames := make([]string, 0, len(buildArgs))
+	for name := range buildArgs {
+		argNames = append(argNames, name)
 	}
-	return nil
+	sort.Strings(argNames)
+	for _, name := range argNames {
+		logctx = append(logctx, name, buildArgs[name])
+		opts.BuildArgs = append(opts.BuildArgs, docker.BuildArg{Name: name, Value: buildArgs[name]})
+	}
+	return opts, logctx
 }
@@ -0,0 +1,187 @@
+package libdocker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+// localSourceDir is the directory containing the source checkout in the build context
+// of local client builds.
+const localSourceDir = "source"
+
+// defaultLocalIgnore lists paths which are never added to the build context.
+var defaultLocalIgnore = []string{".git"}
+
+// buildLocalImage builds a client image from a local source checkout. The build context
+// contains the files of the client directory, and the checkout in the 'source'
+// directory. Files matching the .dockerignore of the checkout are left out.
+func (b *Builder) buildLocalImage(ctx context.Context, clientDir, sourceDir, dockerFile string, buildArgs map[string]string, imageTag string) error {
+	logger := b.logger.New("image", imageTag)
+	source, err := filepath.Abs(sourceDir)
+	if err != nil {
+		logger.Error("can't find path to source directory", "err", err)
+		return err
+	}
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		err = fmt.Errorf("local source %s is not a directory", sourceDir)
+		logger.Error("can't build image", "err", err)
+		return err
+	}
+
+	opts, logctx := b.buildOptions(ctx, dockerFile, buildArgs, imageTag)
+	logctx = append([]interface{}{"dir", clientDir, "source", source}, logctx...)
+
+	// Write the build context to a temporary file, hashing it along the way.
+	tmp, err := os.CreateTemp("", "hive-build-*.tar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := newBuildHash(dockerFile, opts.BuildArgs)
+	if err := archiveLocalContext(io.MultiWriter(tmp, h), clientDir, source); err != nil {
+		logger.Error("can't create build context", "err", err)
+		return err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if !opts.NoCache && !opts.Pull && b.imageUpToDate(imageTag, hash) {
+		logger.Info("image is up to date", logctx...)
+		return nil
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	opts.InputStream = tmp
+	opts.Labels = map[string]string{buildHashLabel: hash}
+
+	logger.Info("building image", logctx...)
+	if err := b.client.BuildImage(opts); err != nil {
+		logger.Error("image build failed", "err", err)
+		return err
+	}
+	return nil
+}
+
+// archiveLocalContext writes the build context of a local client build as a tarball.
+func archiveLocalContext(out io.Writer, clientDir, sourceDir string) error {
+	clientIgnore, err := fileutils.NewPatternMatcher(defaultLocalIgnore)
+	if err != nil {
+		return err
+	}
+	sourceIgnore, err := localIgnorePatterns(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	w := tar.NewWriter(out)
+	if err := archiveDir(w, clientDir, "", clientIgnore); err != nil {
+		return err
+	}
+	if err := archiveDir(w, sourceDir, localSourceDir, sourceIgnore); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// localIgnorePatterns reads the .dockerignore file of a source checkout.
+func localIgnorePatterns(dir string) (*fileutils.PatternMatcher, error) {
+	return ignorePatterns(os.DirFS(dir), dir)
+}
+
+// ignorePatterns reads the .dockerignore file at the root of fsys. The patterns in
+// defaultLocalIgnore are always included. The name of fsys is used in errors.
+func ignorePatterns(fsys fs.FS, name string) (*fileutils.PatternMatcher, error) {
+	patterns := append([]string{}, defaultLocalIgnore...)
+	content, err := fs.ReadFile(fsys, ".dockerignore")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			line = "!" + strings.TrimPrefix(line[1:], "/")
+		} else {
+			line = strings.TrimPrefix(line, "/")
+		}
+		patterns = append(patterns, line)
+	}
+	pm, err := fileutils.NewPatternMatcher(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .dockerignore in %s: %v", name, err)
+	}
+	return pm, nil
+}
+
+// archiveDir adds the files in dir to the tarball, placing them below prefix. File
+// metadata except the mode is dropped, so the tarball only changes when file contents
+// change.
+func archiveDir(w *tar.Writer, dir, prefix string, ignore *fileutils.PatternMatcher) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			if prefix == "" {
+				return nil
+			}
+		} else if skip, err := ignore.Matches(rel); err != nil {
+			return err
+		} else if skip {
+			// Excluded directories can only be skipped entirely if no pattern
+			// re-includes files in them.
+			if info.IsDir() && !ignore.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.ModTime = time.Unix(0, 0)
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	})
+}
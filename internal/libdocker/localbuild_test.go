@@ -0,0 +1,137 @@
+package libdocker
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// localContext creates a client directory and a source checkout.
+func localContext(t *testing.T) (clientDir, sourceDir string) {
+	clientDir, sourceDir = t.TempDir(), t.TempDir()
+	writeFiles(t, clientDir, map[string]string{
+		"Dockerfile.local": "FROM alpine\nCOPY source /src\n",
+		"enode.sh":         "#!/bin/sh\n",
+		".git/HEAD":        "ref: refs/heads/master\n",
+	})
+	writeFiles(t, sourceDir, map[string]string{
+		".dockerignore":   "# comment\n/build\n*.log\n!keep.log\n",
+		"main.go":         "package main\n",
+		"cmd/tool/x.go":   "package tool\n",
+		"keep.log":        "kept",
+		"debug.log":       "ignored",
+		"build/bin/geth":  "binary",
+		".git/HEAD":       "ref: refs/heads/master\n",
+		".git/refs/x/log": "ignored",
+	})
+	return clientDir, sourceDir
+}
+
+func archiveNames(t *testing.T, clientDir, sourceDir string) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := archiveLocalContext(&buf, clientDir, sourceDir); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	r := tar.NewReader(&buf)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestArchiveLocalContext(t *testing.T) {
+	clientDir, sourceDir := localContext(t)
+	names := archiveNames(t, clientDir, sourceDir)
+	want := []string{
+		"Dockerfile.local",
+		"enode.sh",
+		"source/",
+		"source/.dockerignore",
+		"source/cmd/",
+		"source/cmd/tool/",
+		"source/cmd/tool/x.go",
+		"source/keep.log",
+		"source/main.go",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("wrong archive contents\n got: %q\nwant: %q", names, want)
+	}
+}
+
+func TestArchiveLocalContextInvalidIgnore(t *testing.T) {
+	clientDir, sourceDir := localContext(t)
+	writeFiles(t, sourceDir, map[string]string{".dockerignore": "[\n"})
+	if err := archiveLocalContext(io.Discard, clientDir, sourceDir); err == nil {
+		t.Fatal("no error for invalid .dockerignore")
+	}
+}
+
+func TestArchiveLocalContextHash(t *testing.T) {
+	clientDir, sourceDir := localContext(t)
+	hash := func() string {
+		h := newBuildHash("Dockerfile.local", nil)
+		if err := archiveLocalContext(h, clientDir, sourceDir); err != nil {
+			t.Fatal(err)
+		}
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	base := hash()
+
+	// Touching the files must not change the hash.
+	mtime := time.Now().Add(time.Hour)
+	for _, dir := range []string{clientDir, sourceDir} {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(path, mtime, mtime)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h := hash(); h != base {
+		t.Fatal("hash changed after touching files")
+	}
+
+	// Changing ignored files must not change the hash either.
+	writeFiles(t, sourceDir, map[string]string{"debug.log": "changed", "build/bin/geth": "changed"})
+	if h := hash(); h != base {
+		t.Fatal("hash changed after modifying ignored files")
+	}
+
+	// Changing a file in the checkout does.
+	writeFiles(t, sourceDir, map[string]string{"cmd/tool/x.go": "package other\n"})
+	if h := hash(); h == base {
+		t.Fatal("hash didn't change after modifying source file")
+	}
+}
@@ -34,6 +34,7 @@ func newSimulationAPI(b ContainerBackend, env SimEnv, tm *TestManager) http.Hand
 	// API routes.
 	router := mux.NewRouter()
 	router.HandleFunc("/clients", api.getClientTypes).Methods("GET")
+	router.HandleFunc("/catalog", api.addCatalogSuite).Methods("POST")
 	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}/exec", api.execInClient).Methods("POST")
 	router.HandleFunc("/testsuite/{suite}/test/{test}/node/{node}", api.getNodeStatus).Methods("GET")
 	router.HandleFunc("/testsuite/{suite}/test/{test}/node", api.startClient).Methods("POST")
@@ -69,8 +70,31 @@ func (api *simAPI) getClientTypes(w http.ResponseWriter, r *http.Request) {
 	serveJSON(w, clients)
 }
 
+// addCatalogSuite records a suite reported in list-only mode.
+func (api *simAPI) addCatalogSuite(w http.ResponseWriter, r *http.Request) {
+	var suite simapi.CatalogSuite
+	if err := json.NewDecoder(r.Body).Decode(&suite); err != nil {
+		serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if suite.Name == "" {
+		serveError(w, errors.New("suite name is empty"), http.StatusBadRequest)
+		return
+	}
+	if suite.Tests == nil {
+		suite.Tests = []simapi.CatalogTest{}
+	}
+	api.tm.AddCatalogSuite(suite)
+	log15.Info("API: suite listed", "name", suite.Name, "tests", len(suite.Tests))
+	serveOK(w)
+}
+
 // startSuite starts a suite.
 func (api *simAPI) startSuite(w http.ResponseWriter, r *http.Request) {
+	if api.env.SimListOnly {
+		serveError(w, ErrListOnly, http.StatusBadRequest)
+		return
+	}
 	var suite simapi.TestRequest
 	if err := json.NewDecoder(r.Body).Decode(&suite); err != nil {
 		serveError(w, err, http.StatusBadRequest)
@@ -85,6 +109,7 @@ func (api *simAPI) startSuite(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log15.Error("API: StartTestSuite failed", "error", err)
 		serveError(w, err, http.StatusInternalServerError)
+		return
 	}
 	log15.Info("API: suite started", "suite", suiteID, "name", suite.Name)
 	serveJSON(w, suiteID)
@@ -108,6 +133,10 @@ func (api *simAPI) endSuite(w http.ResponseWriter, r *http.Request) {
 
 // startTest signals the start of a test case.
 func (api *simAPI) startTest(w http.ResponseWriter, r *http.Request) {
+	if api.env.SimListOnly {
+		serveError(w, ErrListOnly, http.StatusBadRequest)
+		return
+	}
 	suiteID, err := api.requestSuite(r)
 	if err != nil {
 		serveError(w, err, http.StatusBadRequest)
@@ -123,13 +152,13 @@ func (api *simAPI) startTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testID, err := api.tm.StartTest(suiteID, test.Name, test.Description)
+	testID, err := api.tm.StartTest(suiteID, TestID(test.Parent), test.Name, test.Description, test.Tags)
 	if err != nil {
 		err := fmt.Errorf("can't start test case: %s", err.Error())
 		serveError(w, err, http.StatusInternalServerError)
 		return
 	}
-	log15.Info("API: test started", "suite", suiteID, "test", testID, "name", test.Name)
+	log15.Info("API: test started", "suite", suiteID, "test", testID, "parent", test.Parent, "name", test.Name)
 	serveJSON(w, testID)
 }
 
@@ -164,6 +193,10 @@ func (api *simAPI) endTest(w http.ResponseWriter, r *http.Request) {
 
 // startClient starts a client container.
 func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
+	if api.env.SimListOnly {
+		serveError(w, ErrListOnly, http.StatusBadRequest)
+		return
+	}
 	suiteID, testID, err := api.requestSuiteAndTest(r)
 	if err != nil {
 		serveError(w, err, http.StatusBadRequest)
@@ -301,7 +334,7 @@ func (api *simAPI) startClient(w http.ResponseWriter, r *http.Request) {
 	}
 	if err != nil {
 		log15.Error("API: could not start client", "client", clientDef.Name, "container", containerID[:8], "error", err)
-		err := fmt.Errorf("client did not start: %v", err)
+		err := fmt.Errorf("%w: %v", ErrClientStartFailed, err)
 		serveError(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -324,11 +357,11 @@ func (api *simAPI) clientLogFilePaths(clientName, containerID string) (jsonPath
 
 func (api *simAPI) checkClient(req *simapi.NodeConfig) (*ClientDefinition, error) {
 	if req.Client == "" {
-		return nil, errors.New("missing client type in start request")
+		return nil, fmt.Errorf("%w in start request", ErrMissingClientType)
 	}
 	def, ok := api.tm.clientDefs[req.Client]
 	if !ok {
-		return nil, errors.New("unknown client type in start request")
+		return nil, fmt.Errorf("%w %q in start request", ErrUnknownClientType, req.Client)
 	}
 	return def, nil
 }
@@ -337,7 +370,7 @@ func (api *simAPI) checkClient(req *simapi.NodeConfig) (*ClientDefinition, error
 func (api *simAPI) checkClientNetworks(req *simapi.NodeConfig, suiteID TestSuiteID) ([]string, error) {
 	for _, network := range req.Networks {
 		if !api.tm.NetworkExists(suiteID, network) {
-			return nil, fmt.Errorf("invalid network name '%s' in client start request", network)
+			return nil, fmt.Errorf("invalid network name '%s' in client start request (%w)", network, ErrNetworkNotFound)
 		}
 	}
 	return req.Networks, nil
@@ -432,6 +465,10 @@ func parseExecRequest(r io.Reader) ([]string, error) {
 
 // networkCreate creates a docker network.
 func (api *simAPI) networkCreate(w http.ResponseWriter, r *http.Request) {
+	if api.env.SimListOnly {
+		serveError(w, ErrListOnly, http.StatusBadRequest)
+		return
+	}
 	suiteID, err := api.requestSuite(r)
 	if err != nil {
 		serveError(w, err, http.StatusBadRequest)
@@ -537,7 +574,7 @@ func (api *simAPI) requestSuite(r *http.Request) (TestSuiteID, error) {
 	}
 	testSuiteID := TestSuiteID(testSuite)
 	if _, running := api.tm.IsTestSuiteRunning(testSuiteID); !running {
-		return 0, fmt.Errorf("test suite %d not running", testSuite)
+		return 0, fmt.Errorf("%w: %d", ErrNoSuchTestSuite, testSuite)
 	}
 	return testSuiteID, nil
 }
@@ -553,7 +590,7 @@ func (api *simAPI) requestTest(r *http.Request) (TestID, error) {
 	}
 	testCaseID := TestID(testCase)
 	if _, running := api.tm.IsTestRunning(testCaseID); !running {
-		return 0, fmt.Errorf("test case %d is not running", testCaseID)
+		return 0, fmt.Errorf("%w: %d", ErrNoSuchTestCase, testCaseID)
 	}
 	return testCaseID, nil
 }
@@ -587,8 +624,30 @@ func serveOK(w http.ResponseWriter) {
 }
 
 func serveError(w http.ResponseWriter, err error, status int) {
-	resp, _ := json.Marshal(&simapi.Error{Error: err.Error()})
+	resp, _ := json.Marshal(&simapi.Error{Error: err.Error(), Code: errorCode(err)})
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(status)
 	w.Write(resp)
 }
+
+// errorCode returns the API error code corresponding to err.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrNoSuchNode):
+		return simapi.ErrCodeNoSuchNode
+	case errors.Is(err, ErrNoSuchTestSuite):
+		return simapi.ErrCodeSuiteNotRunning
+	case errors.Is(err, ErrNoSuchTestCase):
+		return simapi.ErrCodeTestNotRunning
+	case errors.Is(err, ErrTestSuiteRunning):
+		return simapi.ErrCodeSuiteHasTests
+	case errors.Is(err, ErrNetworkNotFound):
+		return simapi.ErrCodeNoSuchNetwork
+	case errors.Is(err, ErrUnknownClientType):
+		return simapi.ErrCodeUnknownClient
+	case errors.Is(err, ErrClientStartFailed):
+		return simapi.ErrCodeClientStartFailed
+	default:
+		return ""
+	}
+}
@@ -28,12 +28,16 @@ type TestSuite struct {
 	TestCases      map[TestID]*TestCase `json:"testCases"`
 	// the log-file pertaining to the simulator. (may encompass more than just one TestSuite)
 	SimulatorLog string `json:"simLog"`
+	// the run configuration file of the hive run which created the suite.
+	RunConfig string `json:"runConfig,omitempty"`
 }
 
 // TestCase represents a single test case in a test suite.
 type TestCase struct {
-	Name          string                 `json:"name"`        // Test case short name.
-	Description   string                 `json:"description"` // Test case long description in MD.
+	Name          string                 `json:"name"`             // Test case short name.
+	Description   string                 `json:"description"`      // Test case long description in MD.
+	Parent        TestID                 `json:"parent,omitempty"` // ID of the parent test case, if any.
+	Tags          []string               `json:"tags,omitempty"`   // Test case tags.
 	Start         time.Time              `json:"start"`
 	End           time.Time              `json:"end"`
 	SummaryResult TestResult             `json:"summaryResult"` // The result of the whole test case.
@@ -8,6 +8,7 @@ import (
 	"mime/multipart"
 	"net"
 	"net/http"
+	"time"
 )
 
 // ContainerBackend captures the docker interactions of the simulation API.
@@ -78,8 +79,7 @@ type ContainerInfo struct {
 
 // Builder can build docker images of clients and simulators.
 type Builder interface {
-	ReadClientMetadata(name string) (*ClientMetadata, error)
-	BuildClientImage(ctx context.Context, name string) (string, error)
+	BuildClientImage(ctx context.Context, client ClientDesignator) (string, error)
 	BuildSimulatorImage(ctx context.Context, name string) (string, error)
 	BuildImage(ctx context.Context, name string, fsys fs.FS) error
 
@@ -91,3 +91,29 @@ type Builder interface {
 type ClientMetadata struct {
 	Roles []string `yaml:"roles" json:"roles"`
 }
+
+// SimulatorMetadata describes a simulator, configured with a YAML file in the simulator dir.
+type SimulatorMetadata struct {
+	Description string `yaml:"description"`
+
+	// Roles lists the client roles required by the simulator. Simulation runs without
+	// a client for every role are skipped.
+	Roles []string `yaml:"roles"`
+
+	// These are the defaults of --sim.limit and --sim.timelimit.
+	TestPattern string        `yaml:"test-pattern"`
+	TimeLimit   time.Duration `yaml:"timelimit"`
+
+	// BuildContext is the build context directory, relative to the simulator dir.
+	BuildContext string `yaml:"build-context"`
+
+	// HelperImages are built before the simulator image.
+	HelperImages []HelperImage `yaml:"helper-images"`
+}
+
+// HelperImage is an image built from a directory in the simulator dir. It is tagged
+// hive/simulators/<simulator>/<name>, and can be used by the simulator Dockerfile.
+type HelperImage struct {
+	Name string `yaml:"name"`
+	Dir  string `yaml:"dir"`
+}
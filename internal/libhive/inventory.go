@@ -1,11 +1,15 @@
 package libhive
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // branchDelimiter is what separates the client name from the branch, eg: besu_nightly, go-ethereum_master.
@@ -19,26 +23,171 @@ func SplitClientName(name string) (string, string) {
 	return name, ""
 }
 
-// Inventory keeps names of clients and simulators.
+// ClientDesignator selects a client and configures the build of its image.
+type ClientDesignator struct {
+	Client     string            // name of the client directory
+	Nametag    string            // distinguishes multiple builds of the same client
+	Dockerfile string            // Dockerfile variant, e.g. "git" for Dockerfile.git
+	BuildArgs  map[string]string // docker build arguments
+
+	// Local is the path of a local source checkout. If set, the client is built from
+	// this directory using the "local" Dockerfile variant by default.
+	Local string
+}
+
+// ParseClientDesignator parses a client in --client flag syntax. This is either
+// 'client_branch', or the client name followed by options:
+//
+//	client:branch=master;user=me;dockerfile=git;nametag=mine
+//
+// The 'dockerfile', 'nametag' and 'local' options set the corresponding fields, all
+// other options are build arguments. A local source checkout can also be given as
+// 'client@path', which may be followed by options.
+func ParseClientDesignator(s string) (ClientDesignator, error) {
+	ix := strings.IndexByte(s, ':')
+	if ix < 0 && !strings.Contains(s, "@") {
+		client, branch := SplitClientName(s)
+		d := ClientDesignator{Client: client}
+		if branch != "" {
+			d.BuildArgs = map[string]string{"branch": branch}
+		}
+		return d, nil
+	}
+
+	var d ClientDesignator
+	head, opts := s, ""
+	if ix >= 0 {
+		head, opts = s[:ix], s[ix+1:]
+	}
+	if at := strings.IndexByte(head, '@'); at >= 0 {
+		d.Client, d.Local = head[:at], head[at+1:]
+		if d.Local == "" {
+			return d, fmt.Errorf("invalid client %q: empty source path", s)
+		}
+	} else {
+		d.Client = head
+	}
+	if d.Client == "" {
+		return d, fmt.Errorf("invalid client %q: empty client name", s)
+	}
+	for _, opt := range strings.Split(opts, ";") {
+		if opt == "" {
+			continue
+		}
+		eq := strings.IndexByte(opt, '=')
+		if eq <= 0 {
+			return d, fmt.Errorf("invalid client %q: option %q is not key=value", s, opt)
+		}
+		key, value := opt[:eq], opt[eq+1:]
+		switch key {
+		case "nametag":
+			d.Nametag = value
+		case "dockerfile":
+			d.Dockerfile = value
+		case "local":
+			d.Local = value
+		default:
+			if d.BuildArgs == nil {
+				d.BuildArgs = make(map[string]string)
+			}
+			d.BuildArgs[key] = value
+		}
+	}
+	return d, nil
+}
+
+// Name returns the name of the client as shown to simulators and in test results.
+// This is the client name, followed by the nametag or the branch build argument.
+// Clients built from local sources are named 'client_local' by default.
+func (d ClientDesignator) Name() string {
+	tag := d.Nametag
+	if tag == "" {
+		tag = d.BuildArgs["branch"]
+	}
+	if tag == "" && d.Local != "" {
+		tag = "local"
+	}
+	if tag == "" {
+		return d.Client
+	}
+	return d.Client + branchDelimiter + tag
+}
+
+// String returns the client in --client flag syntax.
+func (d ClientDesignator) String() string {
+	// The 'client_branch' syntax can't be used for clients with '_' in their name.
+	if d.Nametag == "" && d.Dockerfile == "" && d.Local == "" && !strings.Contains(d.Client, branchDelimiter) {
+		switch {
+		case len(d.BuildArgs) == 0:
+			return d.Client
+		case len(d.BuildArgs) == 1 && d.BuildArgs["branch"] != "":
+			return d.Client + branchDelimiter + d.BuildArgs["branch"]
+		}
+	}
+	var opts []string
+	for k, v := range d.BuildArgs {
+		opts = append(opts, k+"="+v)
+	}
+	sort.Strings(opts)
+	if d.Dockerfile != "" {
+		opts = append(opts, "dockerfile="+d.Dockerfile)
+	}
+	if d.Nametag != "" {
+		opts = append(opts, "nametag="+d.Nametag)
+	}
+	if d.Local != "" {
+		opts = append(opts, "local="+d.Local)
+	}
+	return d.Client + ":" + strings.Join(opts, ";")
+}
+
+// Inventory keeps names and metadata of clients and simulators.
 type Inventory struct {
 	BaseDir    string
-	Clients    map[string]struct{}
-	Simulators map[string]struct{}
+	Clients    map[string]ClientMetadata
+	Simulators map[string]SimulatorMetadata
 }
 
 // HasClient returns true if the inventory contains the given client.
 // The client name may contain a branch specifier.
 func (inv Inventory) HasClient(name string) bool {
-	name, _ = SplitClientName(name)
-	_, ok := inv.Clients[name]
+	_, ok := inv.Clients[inv.clientName(name)]
 	return ok
 }
 
 // ClientDirectory returns the directory containing the given client's Dockerfile.
 // The client name may contain a branch specifier.
 func (inv Inventory) ClientDirectory(name string) string {
+	return filepath.Join(inv.BaseDir, "clients", filepath.FromSlash(inv.clientName(name)))
+}
+
+// clientName removes the branch specifier from name. Names of clients in the
+// inventory are returned unchanged, even if they contain the branch delimiter.
+func (inv Inventory) clientName(name string) string {
+	if _, ok := inv.Clients[name]; ok {
+		return name
+	}
 	name, _ = SplitClientName(name)
-	return filepath.Join(inv.BaseDir, "clients", filepath.FromSlash(name))
+	return name
+}
+
+// ClientDockerfile returns the name of a Dockerfile variant in the client directory.
+// The variant "git" selects Dockerfile.git or git.Dockerfile. An empty variant selects
+// the default Dockerfile.
+func (inv Inventory) ClientDockerfile(name, variant string) (string, error) {
+	if variant == "" {
+		return "Dockerfile", nil
+	}
+	dir := inv.ClientDirectory(name)
+	for _, file := range []string{"Dockerfile." + variant, variant + ".Dockerfile", variant} {
+		if !strings.Contains(file, "Dockerfile") || strings.ContainsAny(file, "/\\") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, file)); err == nil && info.Mode().IsRegular() {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("client %s has no Dockerfile variant %q", name, variant)
 }
 
 // HasSimulator returns true if the inventory contains the given simulator.
@@ -54,20 +203,46 @@ func (inv Inventory) SimulatorDirectory(name string) string {
 
 // AddClient ensures the given client name is known to the inventory.
 // This method exists for unit testing purposes only.
-func (inv *Inventory) AddClient(name string) {
+func (inv *Inventory) AddClient(name string, meta *ClientMetadata) {
 	if inv.Clients == nil {
-		inv.Clients = make(map[string]struct{})
+		inv.Clients = make(map[string]ClientMetadata)
 	}
-	inv.Clients[name] = struct{}{}
+	if meta == nil {
+		meta = defaultClientMetadata()
+	}
+	inv.Clients[name] = *meta
 }
 
 // AddSimulator ensures the given simulator name is known to the inventory.
 // This method exists for unit testing purposes only.
-func (inv *Inventory) AddSimulator(name string) {
+func (inv *Inventory) AddSimulator(name string, meta *SimulatorMetadata) {
 	if inv.Simulators == nil {
-		inv.Simulators = make(map[string]struct{})
+		inv.Simulators = make(map[string]SimulatorMetadata)
+	}
+	if meta == nil {
+		meta = new(SimulatorMetadata)
+	}
+	inv.Simulators[name] = *meta
+}
+
+// ClientNames returns the sorted names of all clients.
+func (inv *Inventory) ClientNames() []string {
+	names := make([]string, 0, len(inv.Clients))
+	for name := range inv.Clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SimulatorNames returns the sorted names of all simulators.
+func (inv *Inventory) SimulatorNames() []string {
+	names := make([]string, 0, len(inv.Simulators))
+	for name := range inv.Simulators {
+		names = append(names, name)
 	}
-	inv.Simulators[name] = struct{}{}
+	sort.Strings(names)
+	return names
 }
 
 // MatchSimulators returns matching simulator names.
@@ -90,20 +265,85 @@ func (inv *Inventory) MatchSimulators(expr string) ([]string, error) {
 	return result, nil
 }
 
-// LoadInventory finds all clients and simulators in basedir.
+// LoadInventory finds all clients and simulators in basedir, and reads their metadata.
 func LoadInventory(basedir string) (Inventory, error) {
-	var err error
-	inv := Inventory{BaseDir: basedir}
-	inv.Clients, err = findDockerfiles(filepath.Join(basedir, "clients"))
+	inv := Inventory{
+		BaseDir:    basedir,
+		Clients:    make(map[string]ClientMetadata),
+		Simulators: make(map[string]SimulatorMetadata),
+	}
+	clients, err := findDockerfiles(filepath.Join(basedir, "clients"))
 	if err != nil {
 		return inv, err
 	}
-	inv.Simulators, err = findDockerfiles(filepath.Join(basedir, "simulators"))
-	return inv, err
+	for _, name := range clients {
+		meta := defaultClientMetadata()
+		if err := readMetadata(inv.ClientDirectory(name), meta); err != nil {
+			return inv, fmt.Errorf("client %s: %v", name, err)
+		}
+		inv.Clients[name] = *meta
+	}
+	sims, err := findDockerfiles(filepath.Join(basedir, "simulators"))
+	if err != nil {
+		return inv, err
+	}
+	for _, name := range sims {
+		meta, err := readSimulatorMetadata(inv.SimulatorDirectory(name))
+		if err != nil {
+			return inv, fmt.Errorf("simulator %s: %v", name, err)
+		}
+		inv.Simulators[name] = *meta
+	}
+	return inv, nil
+}
+
+func defaultClientMetadata() *ClientMetadata {
+	// Eth1 client by default.
+	return &ClientMetadata{Roles: []string{"eth1"}}
+}
+
+func readSimulatorMetadata(dir string) (*SimulatorMetadata, error) {
+	meta := new(SimulatorMetadata)
+	if err := readMetadata(dir, meta); err != nil {
+		return nil, err
+	}
+	// The build context used to be configured by context.txt.
+	if meta.BuildContext == "" {
+		content, err := os.ReadFile(filepath.Join(dir, "context.txt"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		meta.BuildContext = strings.TrimSpace(string(content))
+	}
+	for _, img := range meta.HelperImages {
+		if img.Name == "" || img.Dir == "" {
+			return nil, fmt.Errorf("invalid helper image in %s, name and dir are required", metadataFile)
+		}
+	}
+	return meta, nil
+}
+
+// metadataFile is the name of the metadata file in client and simulator directories.
+const metadataFile = "hive.yaml"
+
+// readMetadata decodes the metadata file in dir into out. It does nothing if the
+// directory has no metadata file.
+func readMetadata(dir string, out interface{}) error {
+	f, err := os.Open(filepath.Join(dir, metadataFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read hive metadata file in '%s': %v", dir, err)
+	}
+	defer f.Close()
+	if err := yaml.NewDecoder(f).Decode(out); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode hive metadata file in '%s': %v", dir, err)
+	}
+	return nil
 }
 
-func findDockerfiles(dir string) (map[string]struct{}, error) {
-	names := make(map[string]struct{})
+func findDockerfiles(dir string) ([]string, error) {
+	var names []string
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -111,8 +351,7 @@ func findDockerfiles(dir string) (map[string]struct{}, error) {
 		// If we hit a dockerfile, add the parent and stop looking in this directory.
 		if strings.HasSuffix(path, "Dockerfile") {
 			rel, _ := filepath.Rel(dir, filepath.Dir(path))
-			name := filepath.ToSlash(rel)
-			names[name] = struct{}{}
+			names = append(names, filepath.ToSlash(rel))
 			return filepath.SkipDir
 		}
 		return nil
@@ -2,6 +2,7 @@ package libhive_test
 
 import (
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/hive/internal/libhive"
@@ -24,6 +25,79 @@ func TestSplitClientName(t *testing.T) {
 	}
 }
 
+func TestParseClientDesignator(t *testing.T) {
+	tests := []struct {
+		spec     string
+		want     libhive.ClientDesignator
+		wantName string
+	}{
+		{
+			spec:     "client",
+			want:     libhive.ClientDesignator{Client: "client"},
+			wantName: "client",
+		},
+		{
+			spec:     "the_client_b",
+			want:     libhive.ClientDesignator{Client: "the_client", BuildArgs: map[string]string{"branch": "b"}},
+			wantName: "the_client_b",
+		},
+		{
+			spec:     "the_client:",
+			want:     libhive.ClientDesignator{Client: "the_client"},
+			wantName: "the_client",
+		},
+		{
+			spec:     "the_client:user=me;tag=v1",
+			want:     libhive.ClientDesignator{Client: "the_client", BuildArgs: map[string]string{"user": "me", "tag": "v1"}},
+			wantName: "the_client",
+		},
+		{
+			spec: "client:branch=b;dockerfile=git;nametag=fork;repo=r",
+			want: libhive.ClientDesignator{
+				Client:     "client",
+				Nametag:    "fork",
+				Dockerfile: "git",
+				BuildArgs:  map[string]string{"branch": "b", "repo": "r"},
+			},
+			wantName: "client_fork",
+		},
+		{
+			spec:     "client@/src/client",
+			want:     libhive.ClientDesignator{Client: "client", Local: "/src/client"},
+			wantName: "client_local",
+		},
+		{
+			spec:     "client@../client:nametag=patch;dockerfile=dev",
+			want:     libhive.ClientDesignator{Client: "client", Nametag: "patch", Dockerfile: "dev", Local: "../client"},
+			wantName: "client_patch",
+		},
+	}
+	for _, test := range tests {
+		d, err := libhive.ParseClientDesignator(test.spec)
+		if err != nil {
+			t.Errorf("ParseClientDesignator(%q) error: %v", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, test.want) {
+			t.Errorf("ParseClientDesignator(%q) -> %+v, want %+v", test.spec, d, test.want)
+		}
+		if name := d.Name(); name != test.wantName {
+			t.Errorf("ParseClientDesignator(%q).Name() -> %q, want %q", test.spec, name, test.wantName)
+		}
+		// String must return a spec which parses to the same designator.
+		d2, err := libhive.ParseClientDesignator(d.String())
+		if err != nil || !reflect.DeepEqual(d2, d) {
+			t.Errorf("ParseClientDesignator(%q) -> %+v, %v, want %+v", d.String(), d2, err, d)
+		}
+	}
+
+	for _, spec := range []string{":branch=b", "client:branch", "client:=b", "client@", "@/src"} {
+		if _, err := libhive.ParseClientDesignator(spec); err == nil {
+			t.Errorf("ParseClientDesignator(%q) returned no error", spec)
+		}
+	}
+}
+
 func TestInventory(t *testing.T) {
 	basedir := filepath.FromSlash("../..")
 	inv, err := libhive.LoadInventory(basedir)
@@ -42,6 +116,38 @@ func TestInventory(t *testing.T) {
 			t.Error("returned true for unknown client")
 		}
 	})
+	t.Run("ClientDirectory", func(t *testing.T) {
+		want := filepath.Join(basedir, "clients", "go-ethereum")
+		if dir := inv.ClientDirectory("go-ethereum_latest"); dir != want {
+			t.Errorf("wrong directory %q for go-ethereum_latest, want %q", dir, want)
+		}
+	})
+	t.Run("ClientDockerfile", func(t *testing.T) {
+		if f, err := inv.ClientDockerfile("lighthouse-bn", ""); err != nil || f != "Dockerfile" {
+			t.Errorf("wrong default Dockerfile %q, err %v", f, err)
+		}
+		if f, err := inv.ClientDockerfile("lighthouse-bn", "minimal"); err != nil || f != "minimal.Dockerfile" {
+			t.Errorf("wrong Dockerfile %q for variant minimal, err %v", f, err)
+		}
+		if _, err := inv.ClientDockerfile("lighthouse-bn", "unknown"); err == nil {
+			t.Error("no error for unknown variant")
+		}
+	})
+	t.Run("Metadata", func(t *testing.T) {
+		if roles := inv.Clients["lighthouse-bn"].Roles; !reflect.DeepEqual(roles, []string{"beacon"}) {
+			t.Errorf("wrong lighthouse-bn roles %v", roles)
+		}
+		if roles := inv.Clients["besu"].Roles; !reflect.DeepEqual(roles, []string{"eth1"}) {
+			t.Errorf("wrong default roles %v", roles)
+		}
+		meta := inv.Simulators["optimism/rpc"]
+		if meta.BuildContext != "../../.." {
+			t.Errorf("wrong build context %q", meta.BuildContext)
+		}
+		if len(meta.Roles) == 0 || meta.Description == "" {
+			t.Errorf("missing simulator metadata: %+v", meta)
+		}
+	})
 	t.Run("HasSimulator", func(t *testing.T) {
 		if !inv.HasSimulator("smoke/genesis") {
 			t.Error("can't find smoke/genesis simulator")
@@ -51,3 +157,19 @@ func TestInventory(t *testing.T) {
 		}
 	})
 }
+
+func TestInventoryClientWithDelimiter(t *testing.T) {
+	inv := libhive.Inventory{
+		BaseDir: "base",
+		Clients: map[string]libhive.ClientMetadata{"the_client": {}},
+	}
+	if !inv.HasClient("the_client") || !inv.HasClient("the_client_b") {
+		t.Error("can't find client with delimiter in its name")
+	}
+	want := filepath.Join("base", "clients", "the_client")
+	for _, name := range []string{"the_client", "the_client_b"} {
+		if dir := inv.ClientDirectory(name); dir != want {
+			t.Errorf("wrong directory %q for %s, want %q", dir, name, want)
+		}
+	}
+}
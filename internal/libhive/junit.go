@@ -0,0 +1,96 @@
+package libhive
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JUnitFileName returns the name of the JUnit XML file belonging to a suite file.
+func JUnitFileName(suiteFile string) string {
+	return strings.TrimSuffix(suiteFile, ".json") + ".xml"
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitFile writes the suite result in JUnit XML format.
+func writeJUnitFile(s *TestSuite, logdir, name string) error {
+	suite := junitTestSuite{Name: s.Name}
+	ids := make([]TestID, 0, len(s.TestCases))
+	for id := range s.TestCases {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var start, end time.Time
+	for _, id := range ids {
+		test := s.TestCases[id]
+		tc := junitTestCase{
+			Name:      test.Name,
+			ClassName: s.Name,
+			Time:      test.End.Sub(test.Start).Seconds(),
+		}
+		if test.SummaryResult.Pass {
+			tc.SystemOut = test.SummaryResult.Details
+		} else {
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: "test failed", Text: test.SummaryResult.Details}
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		if start.IsZero() || test.Start.Before(start) {
+			start = test.Start
+		}
+		if test.End.After(end) {
+			end = test.End
+		}
+	}
+	if !start.IsZero() {
+		suite.Timestamp = start.UTC().Format("2006-01-02T15:04:05")
+		suite.Time = end.Sub(start).Seconds()
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return ioutil.WriteFile(filepath.Join(logdir, name), data, 0644)
+}
+
+func hasFormat(formats []string, format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,14 @@ package libhive
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,8 @@ type Runner struct {
 	// This holds the image names of all built simulators.
 	simImages  map[string]string
 	clientDefs map[string]*ClientDefinition
+
+	uploadFailures int
 }
 
 func NewRunner(inv Inventory, b Builder, cb ContainerBackend) *Runner {
@@ -40,7 +44,7 @@ func NewRunner(inv Inventory, b Builder, cb ContainerBackend) *Runner {
 }
 
 // Build builds client and simulator images.
-func (r *Runner) Build(ctx context.Context, clientList, simList []string) error {
+func (r *Runner) Build(ctx context.Context, clientList []ClientDesignator, simList []string) error {
 	if err := r.container.Build(ctx, r.builder); err != nil {
 		return err
 	}
@@ -50,8 +54,18 @@ func (r *Runner) Build(ctx context.Context, clientList, simList []string) error
 	return r.buildSimulators(ctx, simList)
 }
 
+// Clients returns the names of all successfully built clients.
+func (r *Runner) Clients() []string {
+	names := make([]string, 0, len(r.clientDefs))
+	for name := range r.clientDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // buildClients builds client images.
-func (r *Runner) buildClients(ctx context.Context, clientList []string) error {
+func (r *Runner) buildClients(ctx context.Context, clientList []ClientDesignator) error {
 	if len(clientList) == 0 {
 		return errors.New("client list is empty, cannot simulate")
 	}
@@ -61,12 +75,12 @@ func (r *Runner) buildClients(ctx context.Context, clientList []string) error {
 	var anyBuilt bool
 	log15.Info(fmt.Sprintf("building %d clients...", len(clientList)))
 	for _, client := range clientList {
-		if !r.inv.HasClient(client) {
-			return fmt.Errorf("unknown client %q", client)
+		if !r.inv.HasClient(client.Client) {
+			return fmt.Errorf("unknown client %q", client.Client)
 		}
-		meta, err := r.builder.ReadClientMetadata(client)
-		if err != nil {
-			return err
+		name := client.Name()
+		if _, ok := r.clientDefs[name]; ok {
+			return fmt.Errorf("duplicate client name %q, use nametag to distinguish builds of the same client", name)
 		}
 		image, err := r.builder.BuildClientImage(ctx, client)
 		if err != nil {
@@ -75,13 +89,13 @@ func (r *Runner) buildClients(ctx context.Context, clientList []string) error {
 		anyBuilt = true
 		version, err := r.builder.ReadFile(ctx, image, "/version.txt")
 		if err != nil {
-			log15.Warn("can't read version info of "+client, "image", image, "err", err)
+			log15.Warn("can't read version info of "+name, "image", image, "err", err)
 		}
-		r.clientDefs[client] = &ClientDefinition{
-			Name:    client,
+		r.clientDefs[name] = &ClientDefinition{
+			Name:    name,
 			Version: strings.TrimSpace(string(version)),
 			Image:   image,
-			Meta:    *meta,
+			Meta:    r.inv.Clients[client.Client],
 		}
 	}
 	if !anyBuilt {
@@ -105,6 +119,12 @@ func (r *Runner) buildSimulators(ctx context.Context, simList []string) error {
 	return nil
 }
 
+// UploadFailures returns the number of result files which could not be uploaded to the
+// result store in all runs so far.
+func (r *Runner) UploadFailures() int {
+	return r.uploadFailures
+}
+
 func (r *Runner) Run(ctx context.Context, sim string, env SimEnv) (SimResult, error) {
 	if err := createWorkspace(env.LogDir); err != nil {
 		return SimResult{}, err
@@ -127,6 +147,7 @@ func (r *Runner) RunDevMode(ctx context.Context, env SimEnv, endpoint string) er
 		if err := tm.Terminate(); err != nil {
 			log15.Error("could not terminate test manager", "error", err)
 		}
+		r.uploadFailures += tm.UploadFailures()
 	}()
 
 	log15.Debug("starting simulator API proxy")
@@ -179,12 +200,27 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv) (SimResult, er
 		}
 	}
 
+	// Apply the defaults of the simulator, and skip it if the clients can't provide the
+	// roles it needs.
+	meta := r.inv.Simulators[sim]
+	if missing := missingRoles(meta.Roles, clientDefs); len(missing) > 0 {
+		log15.Warn(fmt.Sprintf("skipping simulation %s, no client with required roles", sim), "roles", strings.Join(missing, ","))
+		return SimResult{Skipped: true}, nil
+	}
+	if env.SimTestPattern == "" {
+		env.SimTestPattern = meta.TestPattern
+	}
+	if env.SimDurationLimit == 0 {
+		env.SimDurationLimit = meta.TimeLimit
+	}
+
 	// Start the simulation API.
 	tm := NewTestManager(env, r.container, clientDefs)
 	defer func() {
 		if err := tm.Terminate(); err != nil {
 			log15.Error("could not terminate test manager", "error", err)
 		}
+		r.uploadFailures += tm.UploadFailures()
 	}()
 
 	log15.Debug("starting simulator API server")
@@ -202,8 +238,24 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv) (SimResult, er
 			"HIVE_PARALLELISM":  strconv.Itoa(env.SimParallelism),
 			"HIVE_LOGLEVEL":     strconv.Itoa(env.SimLogLevel),
 			"HIVE_TEST_PATTERN": env.SimTestPattern,
+			"HIVE_TEST_TAGS":    env.SimTestTags,
 		},
 	}
+	if env.SimListOnly {
+		opts.Env["HIVE_LIST_ONLY"] = "1"
+	}
+	if len(env.SimParams) > 0 {
+		params, err := json.Marshal(env.SimParams)
+		if err != nil {
+			return SimResult{}, err
+		}
+		opts.Env["HIVE_PARAMS"] = string(params)
+	}
+	for k, v := range env.SimExtraEnv {
+		if k != "HIVE_SIMULATOR" {
+			opts.Env[k] = v
+		}
+	}
 	containerID, err := r.container.CreateContainer(ctx, r.simImages[sim], opts)
 	if err != nil {
 		return SimResult{}, err
@@ -253,7 +305,7 @@ func (r *Runner) run(ctx context.Context, sim string, env SimEnv) (SimResult, er
 	}
 
 	// Count the results.
-	var result SimResult
+	result := SimResult{Catalog: tm.Catalog()}
 	for _, suite := range tm.Results() {
 		var suiteFailCounted bool
 		result.Suites++
@@ -298,3 +350,20 @@ func createWorkspace(logdir string) error {
 	}
 	return nil
 }
+
+// missingRoles returns the roles which are not provided by any of the clients.
+func missingRoles(roles []string, clientDefs map[string]*ClientDefinition) []string {
+	provided := make(map[string]bool)
+	for _, def := range clientDefs {
+		for _, role := range def.Meta.Roles {
+			provided[role] = true
+		}
+	}
+	var missing []string
+	for _, role := range roles {
+		if !provided[role] {
+			missing = append(missing, role)
+		}
+	}
+	return missing
+}
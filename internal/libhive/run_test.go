@@ -46,7 +46,11 @@ func TestRunner(t *testing.T) {
 		simOpt  = libhive.SimEnv{LogDir: t.TempDir(), ClientList: simClients}
 		ctx     = context.Background()
 	)
-	if err := runner.Build(ctx, allClients, simList); err != nil {
+	clientList := make([]libhive.ClientDesignator, len(allClients))
+	for i, name := range allClients {
+		clientList[i] = libhive.ClientDesignator{Client: name}
+	}
+	if err := runner.Build(ctx, clientList, simList); err != nil {
 		t.Fatal("Build() failed:", err)
 	}
 	if _, err := runner.Run(context.Background(), "sim-1", simOpt); err != nil {
@@ -54,12 +58,64 @@ func TestRunner(t *testing.T) {
 	}
 }
 
+func TestRunnerSimulatorMetadata(t *testing.T) {
+	var inv libhive.Inventory
+	inv.AddClient("client-1", nil)
+	inv.AddClient("beacon-1", &libhive.ClientMetadata{Roles: []string{"beacon"}})
+	inv.AddSimulator("sim-eth1", &libhive.SimulatorMetadata{Roles: []string{"eth1"}, TestPattern: "default"})
+	inv.AddSimulator("sim-beacon", &libhive.SimulatorMetadata{Roles: []string{"eth1", "beacon"}})
+
+	var started []string
+	b := fakes.NewBuilder(&fakes.BuilderHooks{})
+	cb := fakes.NewContainerBackend(&fakes.BackendHooks{
+		StartContainer: func(image, containerID string, opt libhive.ContainerOptions) (*libhive.ContainerInfo, error) {
+			started = append(started, image)
+			if strings.Contains(image, "sim-eth1") {
+				if p := opt.Env["HIVE_TEST_PATTERN"]; p != "default" {
+					t.Errorf("wrong HIVE_TEST_PATTERN %q, want default from metadata", p)
+				}
+			}
+			return new(libhive.ContainerInfo), nil
+		},
+	})
+	runner := libhive.NewRunner(inv, b, cb)
+	clients := []libhive.ClientDesignator{{Client: "client-1"}, {Client: "beacon-1"}}
+	if err := runner.Build(context.Background(), clients, []string{"sim-eth1", "sim-beacon"}); err != nil {
+		t.Fatal("Build() failed:", err)
+	}
+
+	// The beacon simulator can't run with just the eth1 client.
+	env := libhive.SimEnv{LogDir: t.TempDir(), ClientList: []string{"client-1"}}
+	result, err := runner.Run(context.Background(), "sim-beacon", env)
+	if err != nil {
+		t.Fatal("Run() failed:", err)
+	}
+	if !result.Skipped {
+		t.Fatal("simulator with missing roles was not skipped")
+	}
+	if len(started) > 0 {
+		t.Fatal("containers started for skipped simulator:", started)
+	}
+
+	// With both clients, it runs.
+	env.ClientList = nil
+	if result, err = runner.Run(context.Background(), "sim-beacon", env); err != nil {
+		t.Fatal("Run() failed:", err)
+	}
+	if result.Skipped {
+		t.Fatal("simulator skipped although all roles are provided")
+	}
+	if _, err := runner.Run(context.Background(), "sim-eth1", env); err != nil {
+		t.Fatal("Run() failed:", err)
+	}
+}
+
 func makeTestInventory() libhive.Inventory {
 	var inv libhive.Inventory
-	inv.AddClient("client-1")
-	inv.AddClient("client-2")
-	inv.AddClient("client-3")
-	inv.AddSimulator("sim-1")
+	inv.AddClient("client-1", nil)
+	inv.AddClient("client-2", nil)
+	inv.AddClient("client-3", nil)
+	inv.AddSimulator("sim-1", nil)
 	return inv
 }
 
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/hive/internal/logstore"
+	"github.com/ethereum/hive/internal/simapi"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -20,22 +24,48 @@ var (
 	ErrNoSuchTestSuite          = errors.New("no such test suite")
 	ErrNoSuchTestCase           = errors.New("no such test case")
 	ErrMissingClientType        = errors.New("missing client type")
+	ErrUnknownClientType        = errors.New("unknown client type")
+	ErrClientStartFailed        = errors.New("client did not start")
 	ErrNoAvailableClients       = errors.New("no available clients")
 	ErrTestSuiteRunning         = errors.New("test suite still has running tests")
 	ErrMissingOutputDestination = errors.New("test suite requires an output")
 	ErrNoSummaryResult          = errors.New("test case must be ended with a summary result")
 	ErrDBUpdateFailed           = errors.New("could not update results set")
 	ErrTestSuiteLimited         = errors.New("testsuite test count is limited")
+	ErrListOnly                 = errors.New("tests can't be run in list-only mode")
 )
 
 // SimEnv contains the simulation parameters.
 type SimEnv struct {
 	LogDir string
 
+	// If set, the result files in LogDir are also copied to this store
+	// at the end of each test suite.
+	ResultStore logstore.Store
+
+	// Additional formats of suite result files. JSON files are always written.
+	// Supported formats are "json" and "junit".
+	ResultFormats []string
+
+	// The run configuration file in LogDir, which is referenced by suites.
+	RunConfigFile string
+
 	// Parameters of simulation.
 	SimLogLevel    int
 	SimParallelism int
 	SimTestPattern string
+	SimTestTags    string
+
+	// Simulator parameters, passed to the simulator as HIVE_PARAMS.
+	SimParams map[string]string
+
+	// Additional environment variables of the simulator container. These
+	// override the HIVE_* variables above, except for HIVE_SIMULATOR.
+	SimExtraEnv map[string]string
+
+	// In list-only mode, simulators report their tests to the catalog
+	// instead of running them.
+	SimListOnly bool
 
 	// This is the time limit for the simulation run.
 	// There is no default limit.
@@ -56,6 +86,13 @@ type SimResult struct {
 	SuitesFailed int
 	Tests        int
 	TestsFailed  int
+
+	// Catalog contains the suites reported in list-only mode.
+	Catalog []simapi.CatalogSuite
+
+	// Skipped is set when the simulator didn't run because the clients
+	// don't provide the roles it requires.
+	Skipped bool
 }
 
 // TestManager collects test results during a simulation run.
@@ -79,6 +116,12 @@ type TestManager struct {
 	testSuiteCounter  uint32
 	testCaseCounter   uint32
 	results           map[TestSuiteID]*TestSuite
+
+	catalogMutex sync.Mutex
+	catalog      []simapi.CatalogSuite
+
+	uploads        sync.WaitGroup // running result uploads
+	uploadFailures uint32         // number of files which could not be uploaded
 }
 
 func NewTestManager(config SimEnv, b ContainerBackend, clients map[string]*ClientDefinition) *TestManager {
@@ -113,6 +156,22 @@ func (manager *TestManager) Results() map[TestSuiteID]*TestSuite {
 	return r
 }
 
+// AddCatalogSuite records a suite reported in list-only mode.
+func (manager *TestManager) AddCatalogSuite(suite simapi.CatalogSuite) {
+	manager.catalogMutex.Lock()
+	defer manager.catalogMutex.Unlock()
+
+	manager.catalog = append(manager.catalog, suite)
+}
+
+// Catalog returns the suites reported in list-only mode.
+func (manager *TestManager) Catalog() []simapi.CatalogSuite {
+	manager.catalogMutex.Lock()
+	defer manager.catalogMutex.Unlock()
+
+	return append([]simapi.CatalogSuite(nil), manager.catalog...)
+}
+
 // API returns the simulation API handler.
 func (manager *TestManager) API() http.Handler {
 	return newSimulationAPI(manager.backend, manager.config, manager)
@@ -142,6 +201,7 @@ func (manager *TestManager) Terminate() error {
 		Pass:    false,
 		Details: "Test was terminated by host",
 	}
+	defer manager.finishUploads()
 	manager.testSuiteMutex.Lock()
 	defer manager.testSuiteMutex.Unlock()
 
@@ -348,10 +408,18 @@ func (manager *TestManager) doEndSuite(testSuite TestSuiteID) error {
 	}
 	// Write the result.
 	if manager.config.LogDir != "" {
-		err := writeSuiteFile(suite, manager.config.LogDir)
+		suiteFile, err := writeSuiteFile(suite, manager.config.LogDir)
 		if err != nil {
 			return err
 		}
+		if hasFormat(manager.config.ResultFormats, "junit") {
+			if err := writeJUnitFile(suite, manager.config.LogDir, JUnitFileName(suiteFile)); err != nil {
+				return err
+			}
+		}
+		if manager.config.ResultStore != nil {
+			manager.uploadSuite(suite, suiteFile)
+		}
 	}
 	// remove the test suite's left-over docker networks.
 	if errs := manager.PruneNetworks(testSuite); len(errs) > 0 {
@@ -378,13 +446,15 @@ func (manager *TestManager) StartTestSuite(name string, description string) (Tes
 		ClientVersions: make(map[string]string),
 		TestCases:      make(map[TestID]*TestCase),
 		SimulatorLog:   manager.simLogFile,
+		RunConfig:      manager.config.RunConfigFile,
 	}
 	manager.testSuiteCounter++
 	return newSuiteID, nil
 }
 
-//StartTest starts a new test case, returning the testcase id as a context identifier
-func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, description string) (TestID, error) {
+// StartTest starts a new test case, returning the testcase id as a context identifier.
+// If parent is non-zero, the test case is a subtest of the given test.
+func (manager *TestManager) StartTest(testSuiteID TestSuiteID, parent TestID, name string, description string, tags []string) (TestID, error) {
 	manager.testCaseMutex.Lock()
 	defer manager.testCaseMutex.Unlock()
 
@@ -393,6 +463,12 @@ func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, desc
 	if !ok {
 		return 0, ErrNoSuchTestSuite
 	}
+	// check that the parent test belongs to the suite
+	if parent != 0 {
+		if _, ok := testSuite.TestCases[parent]; !ok {
+			return 0, fmt.Errorf("%w: parent test %d", ErrNoSuchTestCase, parent)
+		}
+	}
 	// increment the testcasecounter
 	manager.testCaseCounter++
 	var newCaseID = TestID(manager.testCaseCounter)
@@ -400,6 +476,8 @@ func (manager *TestManager) StartTest(testSuiteID TestSuiteID, name string, desc
 	newTestCase := &TestCase{
 		Name:        name,
 		Description: description,
+		Parent:      parent,
+		Tags:        tags,
 		Start:       time.Now(),
 	}
 	// add the test case to the test suite
@@ -485,10 +563,10 @@ func (manager *TestManager) StopNode(testID TestID, nodeID string) error {
 }
 
 // writeSuiteFile writes the simulation result to the log directory.
-func writeSuiteFile(s *TestSuite, logdir string) error {
+func writeSuiteFile(s *TestSuite, logdir string) (string, error) {
 	suiteData, err := json.Marshal(s)
 	if err != nil {
-		return err
+		return "", err
 	}
 	// Randomize the name, but make it so that it's ordered by date - makes cleanups easier
 	b := make([]byte, 16)
@@ -496,5 +574,64 @@ func writeSuiteFile(s *TestSuite, logdir string) error {
 	suiteFileName := fmt.Sprintf("%v-%x.json", time.Now().Unix(), b)
 	suiteFile := filepath.Join(logdir, suiteFileName)
 	// Write it.
-	return ioutil.WriteFile(suiteFile, suiteData, 0644)
+	return suiteFileName, ioutil.WriteFile(suiteFile, suiteData, 0644)
+}
+
+// uploadSuite copies the result files of a suite to the result store in the background.
+// The suite file is uploaded last, so the store never references missing log files.
+func (manager *TestManager) uploadSuite(suite *TestSuite, suiteFile string) {
+	var files []string
+	for _, test := range suite.TestCases {
+		for _, client := range test.ClientInfo {
+			if client.LogFile != "" {
+				files = append(files, client.LogFile)
+			}
+		}
+	}
+	if suite.SimulatorLog != "" {
+		files = append(files, suite.SimulatorLog)
+	}
+	if suite.RunConfig != "" {
+		files = append(files, suite.RunConfig)
+	}
+	if hasFormat(manager.config.ResultFormats, "junit") {
+		files = append(files, JUnitFileName(suiteFile))
+	}
+	files = append(files, suiteFile)
+
+	manager.uploads.Add(1)
+	go func() {
+		defer manager.uploads.Done()
+		store := manager.config.ResultStore
+		for _, file := range files {
+			err := logstore.Upload(store, manager.config.LogDir, file)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log15.Error("could not upload result file", "suite", suite.Name, "file", file, "store", store, "err", err)
+				atomic.AddUint32(&manager.uploadFailures, 1)
+			}
+		}
+		log15.Info("uploaded suite results", "suite", suite.Name, "store", store, "files", len(files))
+	}()
+}
+
+// finishUploads waits for running result uploads. The simulator log is uploaded
+// again because it may have grown since the last suite ended.
+func (manager *TestManager) finishUploads() {
+	store := manager.config.ResultStore
+	if store == nil {
+		return
+	}
+	manager.uploads.Wait()
+	if manager.simLogFile != "" {
+		if err := logstore.Upload(store, manager.config.LogDir, manager.simLogFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log15.Error("could not upload simulator log", "store", store, "err", err)
+			atomic.AddUint32(&manager.uploadFailures, 1)
+		}
+	}
+}
+
+// UploadFailures returns the number of result files which could not be uploaded to the
+// result store.
+func (manager *TestManager) UploadFailures() int {
+	return int(atomic.LoadUint32(&manager.uploadFailures))
 }
@@ -0,0 +1,148 @@
+// Package logstore provides access to hive result directories. Results can be kept in a
+// local directory or in an S3-compatible bucket.
+package logstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Store is a location where hive result files are kept. File names use '/' as the
+// separator, like in package io/fs.
+type Store interface {
+	fs.ReadDirFS
+	fs.StatFS
+
+	// WriteFile stores a file, replacing any existing file with the same name.
+	WriteFile(name string, content io.Reader) error
+
+	// Remove deletes a file.
+	Remove(name string) error
+
+	// String returns the location of the store.
+	String() string
+}
+
+// IsRemote reports whether location refers to remote storage.
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// Open opens the store at the given location. This can be a local directory path, or an
+// S3 URL of the form "s3://bucket/prefix". S3 credentials and the endpoint are configured
+// through the environment, see S3ConfigFromEnv.
+func Open(location string) (Store, error) {
+	if !IsRemote(location) {
+		return NewDir(location), nil
+	}
+	bucketAndPrefix := strings.TrimPrefix(location, "s3://")
+	bucket, prefix := bucketAndPrefix, ""
+	if i := strings.IndexByte(bucketAndPrefix, '/'); i >= 0 {
+		bucket, prefix = bucketAndPrefix[:i], bucketAndPrefix[i+1:]
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket name in %q", location)
+	}
+	return NewS3(S3ConfigFromEnv(bucket, prefix))
+}
+
+// Upload copies the given files from a local directory to the store.
+func Upload(store Store, dir string, names ...string) error {
+	for _, name := range names {
+		if err := uploadFile(store, dir, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uploadFile(store Store, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.WriteFile(name, f)
+}
+
+// Dir is a Store backed by a local directory.
+type Dir struct {
+	fs.FS
+	dir string
+}
+
+// NewDir creates a store for the given directory.
+func NewDir(dir string) *Dir {
+	return &Dir{FS: os.DirFS(dir), dir: dir}
+}
+
+// Path returns the directory path.
+func (d *Dir) Path() string {
+	return d.dir
+}
+
+func (d *Dir) String() string {
+	return d.dir
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (d *Dir) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(d.FS, name)
+}
+
+// Stat implements fs.StatFS.
+func (d *Dir) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(d.FS, name)
+}
+
+// WriteFile stores a file. The content is written to a temporary file first, so readers
+// never see partially written files.
+func (d *Dir) WriteFile(name string, content io.Reader) error {
+	file, err := d.localPath("write", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+path.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Remove deletes a file.
+func (d *Dir) Remove(name string) error {
+	file, err := d.localPath("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+func (d *Dir) localPath(op, name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+var errIsDir = errors.New("is a directory")
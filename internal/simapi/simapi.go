@@ -1,9 +1,27 @@
 // Package simapi contains definitions of JSON objects used in the simulation API.
 package simapi
 
+// TestRequest is the payload of suite and test creation requests.
 type TestRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Parent      uint32   `json:"parent,omitempty"` // ID of the parent test, for subtests
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// CatalogSuite is the payload of the catalog endpoint. In list-only mode, simulators
+// report their suites and tests through this endpoint instead of running them.
+type CatalogSuite struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Tests       []CatalogTest `json:"tests"`
+}
+
+// CatalogTest describes a test case in the catalog.
+type CatalogTest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags,omitempty"`
 }
 
 // NodeConfig contains the launch parameters for a client container.
@@ -29,6 +47,19 @@ type ExecRequest struct {
 	Command []string `json:"command"`
 }
 
+// Error is the response body of failed API requests.
 type Error struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // one of the ErrCode* constants, if known
 }
+
+// These error codes identify specific failure conditions in Error responses.
+const (
+	ErrCodeNoSuchNode        = "no-such-node"
+	ErrCodeSuiteNotRunning   = "suite-not-running"
+	ErrCodeTestNotRunning    = "test-not-running"
+	ErrCodeSuiteHasTests     = "suite-has-running-tests"
+	ErrCodeNoSuchNetwork     = "no-such-network"
+	ErrCodeUnknownClient     = "unknown-client"
+	ErrCodeClientStartFailed = "client-start-failed"
+)
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// matrixRun is a simulator run of --matrix mode.
+type matrixRun struct {
+	sim     string
+	clients []string
+	result  libhive.SimResult
+	err     error
+}
+
+// clientCombinations returns all combinations of size clients from the list.
+func clientCombinations(clients []string, size int) [][]string {
+	if size <= 0 || size > len(clients) {
+		return nil
+	}
+	var (
+		result [][]string
+		combo  = make([]string, 0, size)
+		add    func(start int)
+	)
+	add = func(start int) {
+		if len(combo) == size {
+			result = append(result, append([]string(nil), combo...))
+			return
+		}
+		for i := start; i < len(clients); i++ {
+			combo = append(combo, clients[i])
+			add(i + 1)
+			combo = combo[:len(combo)-1]
+		}
+	}
+	add(0)
+	return result
+}
+
+// runMatrix runs every simulator once for each combination of clients.
+func runMatrix(ctx context.Context, runner *libhive.Runner, simList []string, simEnv func(string) libhive.SimEnv, size int) ([]matrixRun, error) {
+	combos := clientCombinations(runner.Clients(), size)
+	if len(combos) == 0 {
+		return nil, fmt.Errorf("--matrix.size %d needs at least %d built clients", size, size)
+	}
+
+	var runs []matrixRun
+	for _, sim := range simList {
+		for _, clients := range combos {
+			if ctx.Err() != nil {
+				break
+			}
+			runEnv := simEnv(sim)
+			runEnv.ClientList = clients
+			log15.Info(fmt.Sprintf("running simulation %s with %s", sim, strings.Join(clients, ", ")))
+			result, err := runner.Run(ctx, sim, runEnv)
+			if err != nil {
+				log15.Error(fmt.Sprintf("simulation %s failed", sim), "clients", strings.Join(clients, ","), "err", err)
+			}
+			runs = append(runs, matrixRun{sim: sim, clients: clients, result: result, err: err})
+		}
+	}
+	return runs, nil
+}
+
+// matrixFailures returns the number of failed tests and the number of simulator runs
+// which ended with an error.
+func matrixFailures(runs []matrixRun) (failCount, errCount int) {
+	for _, run := range runs {
+		failCount += run.result.TestsFailed
+		if run.err != nil {
+			errCount++
+		}
+	}
+	return failCount, errCount
+}
+
+// writeMatrixSummary writes the summary table of all runs to a file in dir, and returns
+// the file name.
+func writeMatrixSummary(dir string, runs []matrixRun) (string, error) {
+	var buf bytes.Buffer
+	printMatrixSummary(&buf, runs)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%d-matrix.txt", time.Now().Unix())
+	return name, os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644)
+}
+
+func printMatrixSummary(out io.Writer, runs []matrixRun) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIMULATOR\tCLIENTS\tSUITES\tTESTS\tFAILED\tSTATUS")
+	for _, run := range runs {
+		status := "ok"
+		switch {
+		case run.err != nil:
+			status = "error: " + run.err.Error()
+		case run.result.Skipped:
+			status = "skipped"
+		case run.result.TestsFailed > 0:
+			status = "fail"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", run.sim, strings.Join(run.clients, ","),
+			run.result.Suites, run.result.Tests, run.result.TestsFailed, status)
+	}
+	w.Flush()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/hive/internal/libhive"
+)
+
+func TestClientCombinations(t *testing.T) {
+	clients := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		size int
+		want [][]string
+	}{
+		{0, nil},
+		{5, nil},
+		{1, [][]string{{"a"}, {"b"}, {"c"}, {"d"}}},
+		{2, [][]string{{"a", "b"}, {"a", "c"}, {"a", "d"}, {"b", "c"}, {"b", "d"}, {"c", "d"}}},
+		{3, [][]string{{"a", "b", "c"}, {"a", "b", "d"}, {"a", "c", "d"}, {"b", "c", "d"}}},
+		{4, [][]string{{"a", "b", "c", "d"}}},
+	}
+	for _, test := range tests {
+		result := clientCombinations(clients, test.size)
+		if !reflect.DeepEqual(result, test.want) {
+			t.Errorf("size %d: wrong combinations %v, want %v", test.size, result, test.want)
+		}
+	}
+
+	// The combinations must not share memory.
+	result := clientCombinations(clients, 2)
+	result[0][1] = "x"
+	if result[1][0] != "a" || result[1][1] != "c" {
+		t.Fatal("combinations share backing array")
+	}
+}
+
+func TestRunMatrixTooFewClients(t *testing.T) {
+	runner := libhive.NewRunner(libhive.Inventory{}, nil, nil)
+	runs, err := runMatrix(context.Background(), runner, []string{"sim"}, nil, 2)
+	if err == nil {
+		t.Fatal("no error for matrix without clients")
+	}
+	if runs != nil {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+}
+
+func TestWriteMatrixSummary(t *testing.T) {
+	dir := t.TempDir()
+	runs := []matrixRun{
+		{sim: "sim", clients: []string{"a"}, result: libhive.SimResult{Suites: 1, Tests: 3, TestsFailed: 1}},
+		{sim: "sim", clients: []string{"b"}, err: errors.New("boom")},
+	}
+	name, err := writeMatrixSummary(dir, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	printMatrixSummary(&stdout, runs)
+	if string(content) != stdout.String() {
+		t.Fatalf("summary file differs from printed summary:\n%s", content)
+	}
+	for _, want := range []string{"SIMULATOR", "a", "b", "boom"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("summary doesn't contain %q:\n%s", want, content)
+		}
+	}
+	if failCount, errCount := matrixFailures(runs); failCount != 1 || errCount != 1 {
+		t.Errorf("wrong failures %d, %d", failCount, errCount)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/hive/internal/libhive"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig describes a hive run. It can be loaded from a YAML file using --config.
+// Command-line flags override the settings of the file.
+type runConfig struct {
+	ResultsRoot   string         `yaml:"results-root"`
+	ResultFormats []string       `yaml:"result-formats,omitempty"`
+	LogLevel      int            `yaml:"loglevel"`
+	Docker        dockerSettings `yaml:"docker"`
+
+	Clients       []clientConfig `yaml:"clients"`
+	ClientTimeout duration       `yaml:"client-timeout"`
+
+	// Sim contains the default settings of all simulators.
+	Sim        simSettings `yaml:"sim"`
+	Simulators []simConfig `yaml:"simulators"`
+
+	Matrix     bool `yaml:"matrix,omitempty"`
+	MatrixSize int  `yaml:"matrix-size,omitempty"`
+}
+
+type dockerSettings struct {
+	Pull    bool   `yaml:"pull,omitempty"`
+	NoCache string `yaml:"nocache,omitempty"`
+	Output  bool   `yaml:"output,omitempty"`
+}
+
+// simSettings configures simulator runs. Nil fields are not set.
+type simSettings struct {
+	Limit       *string           `yaml:"limit,omitempty"`
+	Tags        *string           `yaml:"tags,omitempty"`
+	Parallelism *int              `yaml:"parallelism,omitempty"`
+	LogLevel    *int              `yaml:"loglevel,omitempty"`
+	TimeLimit   *duration         `yaml:"timelimit,omitempty"`
+	Params      map[string]string `yaml:"params,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+}
+
+// simConfig is a simulator with its own settings. In YAML, it can also be given as just
+// the simulator name.
+type simConfig struct {
+	Name        string `yaml:"name"`
+	simSettings `yaml:",inline"`
+}
+
+func (s *simConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.Name)
+	}
+	type plain simConfig
+	return node.Decode((*plain)(s))
+}
+
+// clientConfig is a client with an optional branch, i.e. the git branch or docker tag
+// used to build it, and other settings of its image build. In YAML, it can also be given
+// in the syntax of the --client flag.
+type clientConfig struct {
+	Name       string            `yaml:"name"`
+	Branch     string            `yaml:"branch,omitempty"`
+	Nametag    string            `yaml:"nametag,omitempty"`
+	Dockerfile string            `yaml:"dockerfile,omitempty"`
+	BuildArgs  map[string]string `yaml:"build-args,omitempty"`
+	Local      string            `yaml:"local,omitempty"`
+}
+
+// parseClientConfig parses a client in --client flag syntax.
+func parseClientConfig(s string) (clientConfig, error) {
+	d, err := libhive.ParseClientDesignator(s)
+	if err != nil {
+		return clientConfig{}, err
+	}
+	c := clientConfig{Name: d.Client, Nametag: d.Nametag, Dockerfile: d.Dockerfile, Local: d.Local}
+	for k, v := range d.BuildArgs {
+		if k == "branch" {
+			c.Branch = v
+			continue
+		}
+		if c.BuildArgs == nil {
+			c.BuildArgs = make(map[string]string)
+		}
+		c.BuildArgs[k] = v
+	}
+	return c, nil
+}
+
+func (c *clientConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var s string
+		if err := node.Decode(&s); err != nil {
+			return err
+		}
+		var err error
+		*c, err = parseClientConfig(s)
+		return err
+	}
+	type plain clientConfig
+	return node.Decode((*plain)(c))
+}
+
+// designator returns the client selection for building the image.
+func (c clientConfig) designator() libhive.ClientDesignator {
+	d := libhive.ClientDesignator{Client: c.Name, Nametag: c.Nametag, Dockerfile: c.Dockerfile, Local: c.Local}
+	if len(c.BuildArgs) > 0 || c.Branch != "" {
+		d.BuildArgs = make(map[string]string, len(c.BuildArgs)+1)
+		for k, v := range c.BuildArgs {
+			d.BuildArgs[k] = v
+		}
+		if c.Branch != "" {
+			d.BuildArgs["branch"] = c.Branch
+		}
+	}
+	return d
+}
+
+// paramsFlag is a repeatable command-line flag of key=value pairs.
+type paramsFlag map[string]string
+
+func (p paramsFlag) String() string {
+	list := make([]string, 0, len(p))
+	for k, v := range p {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func (p paramsFlag) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("invalid parameter %q, want key=value", s)
+	}
+	p[s[:i]] = s[i+1:]
+	return nil
+}
+
+func (p paramsFlag) Get() interface{} {
+	return map[string]string(p)
+}
+
+// duration is a time.Duration which is written as a string in YAML.
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = duration(v)
+	return err
+}
+
+func (d duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// addRunConfigFlags defines the flags which can also be set in the config file.
+func addRunConfigFlags(flags *flag.FlagSet) {
+	flags.String("results-root", "workspace/logs", "Target `directory` for results files and logs, or S3 location (s3://bucket/prefix) to upload them.")
+	flags.String("results.format", "", "Comma separated `list` of additional result file formats. Supported formats: junit.")
+	flags.Int("loglevel", 3, "Log `level` for system events. Supports values 0-5.")
+	flags.String("docker.nocache", "", "Regular `expression` selecting the docker images to forcibly rebuild.")
+	flags.Bool("docker.pull", false, "Refresh base images when building images.")
+	flags.Bool("docker.output", false, "Relay all docker output to stderr.")
+	flags.String("sim.limit", "", "Regular `expression` selecting tests/suites (interpreted by simulators).")
+	flags.String("sim.tags", "", "Comma separated `list` of test tags to run. Tags prefixed by '!' are excluded (interpreted by simulators).")
+	flags.Int("sim.parallelism", 1, "Max `number` of parallel clients/containers (interpreted by simulators).")
+	flags.Duration("sim.timelimit", 0, "Simulation `timeout`. Hive aborts the simulator if it exceeds this time.")
+	flags.Int("sim.loglevel", 3, "Selects log `level` of client instances. Supports values 0-5.")
+	flags.Var(make(paramsFlag), "sim.param", "Simulator parameter as `key=value`. May be given multiple times (interpreted by simulators).")
+	flags.Bool("matrix", false, "Runs each simulator separately for every client, and prints a summary of all runs.")
+	flags.Int("matrix.size", 1, "Number of clients per simulator run in --matrix mode. Use 2 to run all client pairs.")
+	flags.String("client", "go-ethereum", "Comma separated `list` of clients to use. Client names in the list may be given as\n"+
+		"just the client name, or a client_branch specifier. If a branch name is supplied,\n"+
+		"the client image will use the given git branch or docker tag. Multiple instances of\n"+
+		"a single client type may be requested with different branches.\n"+
+		"Example: \"besu_latest,besu_20.10.2\"")
+	flags.Duration("client.checktimelimit", 3*time.Minute, "The `timeout` of waiting for clients to open up the RPC port.\n"+
+		"If a very long chain is imported, this timeout may need to be quite large.\n"+
+		"A lower value means that hive won't wait as long in case the node crashes and\n"+
+		"never opens the RPC port.")
+}
+
+// loadRunConfig creates the run configuration from the command-line flags and the
+// config file (if not empty). Flags given on the command line take precedence over the
+// file.
+func loadRunConfig(flags *flag.FlagSet, file string) (*runConfig, error) {
+	var (
+		cfg     = new(runConfig)
+		flagErr error
+	)
+	apply := func(f *flag.Flag) {
+		if err := cfg.applyFlag(f); err != nil && flagErr == nil {
+			flagErr = fmt.Errorf("invalid --%s: %v", f.Name, err)
+		}
+	}
+	flags.VisitAll(apply)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", file, err)
+		}
+		flags.Visit(func(f *flag.Flag) {
+			apply(f)
+			cfg.overrideSimSettings(f)
+		})
+	}
+	if flagErr != nil {
+		return nil, flagErr
+	}
+	for _, format := range cfg.ResultFormats {
+		if format != "json" && format != "junit" {
+			return nil, fmt.Errorf("unknown result format %q", format)
+		}
+	}
+	return cfg, nil
+}
+
+// applyFlag sets the configuration value of a flag.
+func (cfg *runConfig) applyFlag(f *flag.Flag) error {
+	value := f.Value.(flag.Getter).Get()
+	switch f.Name {
+	case "results-root":
+		cfg.ResultsRoot = value.(string)
+	case "results.format":
+		cfg.ResultFormats = nil
+		if v := value.(string); v != "" {
+			cfg.ResultFormats = splitAndTrim(v, ",")
+		}
+	case "loglevel":
+		cfg.LogLevel = value.(int)
+	case "docker.pull":
+		cfg.Docker.Pull = value.(bool)
+	case "docker.nocache":
+		cfg.Docker.NoCache = value.(string)
+	case "docker.output":
+		cfg.Docker.Output = value.(bool)
+	case "client":
+		cfg.Clients = nil
+		for _, name := range splitAndTrim(value.(string), ",") {
+			client, err := parseClientConfig(name)
+			if err != nil {
+				return err
+			}
+			cfg.Clients = append(cfg.Clients, client)
+		}
+	case "client.checktimelimit":
+		cfg.ClientTimeout = duration(value.(time.Duration))
+	case "sim.limit":
+		v := value.(string)
+		cfg.Sim.Limit = &v
+	case "sim.tags":
+		v := value.(string)
+		cfg.Sim.Tags = &v
+	case "sim.parallelism":
+		v := value.(int)
+		cfg.Sim.Parallelism = &v
+	case "sim.loglevel":
+		v := value.(int)
+		cfg.Sim.LogLevel = &v
+	case "sim.timelimit":
+		v := duration(value.(time.Duration))
+		cfg.Sim.TimeLimit = &v
+	case "sim.param":
+		for k, v := range value.(map[string]string) {
+			if cfg.Sim.Params == nil {
+				cfg.Sim.Params = make(map[string]string)
+			}
+			cfg.Sim.Params[k] = v
+		}
+	case "matrix":
+		cfg.Matrix = value.(bool)
+	case "matrix.size":
+		cfg.MatrixSize = value.(int)
+	}
+	return nil
+}
+
+// overrideSimSettings removes per-simulator settings which were given as a flag.
+func (cfg *runConfig) overrideSimSettings(f *flag.Flag) {
+	for i := range cfg.Simulators {
+		s := &cfg.Simulators[i].simSettings
+		switch f.Name {
+		case "sim.limit":
+			s.Limit = nil
+		case "sim.tags":
+			s.Tags = nil
+		case "sim.parallelism":
+			s.Parallelism = nil
+		case "sim.loglevel":
+			s.LogLevel = nil
+		case "sim.timelimit":
+			s.TimeLimit = nil
+		case "sim.param":
+			for k := range f.Value.(paramsFlag) {
+				delete(s.Params, k)
+			}
+		}
+	}
+}
+
+// selectSimulators sets the simulator list to the simulators matching the pattern.
+// Settings of simulators in the config file are kept.
+func (cfg *runConfig) selectSimulators(inv libhive.Inventory, pattern string) error {
+	names, err := inv.MatchSimulators(pattern)
+	if err != nil {
+		return fmt.Errorf("bad --sim regular expression: %v", err)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no simulators for pattern %s", pattern)
+	}
+	list := make([]simConfig, len(names))
+	for i, name := range names {
+		list[i] = simConfig{Name: name}
+		if s := cfg.simulator(name); s != nil {
+			list[i] = *s
+		}
+	}
+	cfg.Simulators = list
+	return nil
+}
+
+func (cfg *runConfig) simulator(name string) *simConfig {
+	for i := range cfg.Simulators {
+		if cfg.Simulators[i].Name == name {
+			return &cfg.Simulators[i]
+		}
+	}
+	return nil
+}
+
+// simulatorNames returns the names of the configured simulators.
+func (cfg *runConfig) simulatorNames() []string {
+	names := make([]string, len(cfg.Simulators))
+	for i, s := range cfg.Simulators {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// clientDesignators returns the configured clients.
+func (cfg *runConfig) clientDesignators() []libhive.ClientDesignator {
+	list := make([]libhive.ClientDesignator, len(cfg.Clients))
+	for i, c := range cfg.Clients {
+		list[i] = c.designator()
+	}
+	return list
+}
+
+// simEnv returns the environment of a simulator run. Settings of the simulator override
+// the defaults.
+func (cfg *runConfig) simEnv(base libhive.SimEnv, sim string) libhive.SimEnv {
+	env := base
+	env.ClientStartTimeout = time.Duration(cfg.ClientTimeout)
+	env.ResultFormats = cfg.ResultFormats
+	apply := func(s simSettings) {
+		if s.Limit != nil {
+			env.SimTestPattern = *s.Limit
+		}
+		if s.Tags != nil {
+			env.SimTestTags = *s.Tags
+		}
+		if s.Parallelism != nil {
+			env.SimParallelism = *s.Parallelism
+		}
+		if s.LogLevel != nil {
+			env.SimLogLevel = *s.LogLevel
+		}
+		if s.TimeLimit != nil {
+			env.SimDurationLimit = time.Duration(*s.TimeLimit)
+		}
+		if len(s.Params) > 0 && env.SimParams == nil {
+			env.SimParams = make(map[string]string)
+		}
+		for k, v := range s.Params {
+			env.SimParams[k] = v
+		}
+		if len(s.Env) > 0 && env.SimExtraEnv == nil {
+			env.SimExtraEnv = make(map[string]string)
+		}
+		for k, v := range s.Env {
+			env.SimExtraEnv[k] = v
+		}
+	}
+	apply(cfg.Sim)
+	if s := cfg.simulator(sim); s != nil {
+		apply(s.simSettings)
+	}
+	return env
+}
+
+// write stores the configuration in dir. It returns the file name.
+func (cfg *runConfig) write(dir string) (string, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%d-run.yaml", time.Now().Unix())
+	return name, os.WriteFile(filepath.Join(dir, name), data, 0644)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testRunConfig = `
+results-root: /tmp/results
+loglevel: 4
+clients:
+  - besu_latest
+  - name: go-ethereum
+    branch: master
+sim:
+  parallelism: 2
+  tags: slow
+  params:
+    a: file
+    b: file
+simulators:
+  - devp2p
+  - name: ethereum/rpc
+    parallelism: 8
+    params:
+      a: sim
+`
+
+func loadTestRunConfig(t *testing.T, file string, args ...string) *runConfig {
+	flags := flag.NewFlagSet("hive", flag.ContinueOnError)
+	addRunConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadRunConfig(flags, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestLoadRunConfigDefaults(t *testing.T) {
+	cfg := loadTestRunConfig(t, "")
+	if cfg.ResultsRoot != "workspace/logs" {
+		t.Errorf("wrong results root %q", cfg.ResultsRoot)
+	}
+	if cfg.LogLevel != 3 {
+		t.Errorf("wrong log level %d", cfg.LogLevel)
+	}
+	if len(cfg.Clients) != 1 || cfg.Clients[0].Name != "go-ethereum" {
+		t.Errorf("wrong clients %+v", cfg.Clients)
+	}
+	if cfg.Sim.Parallelism == nil || *cfg.Sim.Parallelism != 1 {
+		t.Errorf("wrong parallelism %v", cfg.Sim.Parallelism)
+	}
+	if time.Duration(cfg.ClientTimeout) != 3*time.Minute {
+		t.Errorf("wrong client timeout %v", time.Duration(cfg.ClientTimeout))
+	}
+}
+
+func TestLoadRunConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(testRunConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings of the file override the flag defaults.
+	cfg := loadTestRunConfig(t, file)
+	if cfg.ResultsRoot != "/tmp/results" || cfg.LogLevel != 4 {
+		t.Errorf("file settings not applied: results root %q, log level %d", cfg.ResultsRoot, cfg.LogLevel)
+	}
+	wantClients := []clientConfig{{Name: "besu", Branch: "latest"}, {Name: "go-ethereum", Branch: "master"}}
+	if !reflect.DeepEqual(cfg.Clients, wantClients) {
+		t.Errorf("wrong clients %+v", cfg.Clients)
+	}
+	if *cfg.Sim.Parallelism != 2 || *cfg.Sim.Tags != "slow" || *cfg.Sim.LogLevel != 3 {
+		t.Errorf("wrong sim settings: parallelism %d, tags %q, loglevel %d", *cfg.Sim.Parallelism, *cfg.Sim.Tags, *cfg.Sim.LogLevel)
+	}
+	if rpc := cfg.simulator("ethereum/rpc"); rpc == nil || *rpc.Parallelism != 8 || rpc.Params["a"] != "sim" {
+		t.Errorf("wrong simulator settings %+v", rpc)
+	}
+
+	// Flags override the file, including per-simulator settings.
+	cfg = loadTestRunConfig(t, file, "--loglevel", "5", "--client", "nethermind", "--sim.parallelism", "4", "--sim.param", "a=flag")
+	if cfg.ResultsRoot != "/tmp/results" || cfg.LogLevel != 5 {
+		t.Errorf("wrong settings: results root %q, log level %d", cfg.ResultsRoot, cfg.LogLevel)
+	}
+	if len(cfg.Clients) != 1 || cfg.Clients[0].Name != "nethermind" {
+		t.Errorf("wrong clients %+v", cfg.Clients)
+	}
+	if *cfg.Sim.Parallelism != 4 || *cfg.Sim.Tags != "slow" {
+		t.Errorf("wrong sim settings: parallelism %d, tags %q", *cfg.Sim.Parallelism, *cfg.Sim.Tags)
+	}
+	if want := map[string]string{"a": "flag", "b": "file"}; !reflect.DeepEqual(cfg.Sim.Params, want) {
+		t.Errorf("wrong sim params %v", cfg.Sim.Params)
+	}
+	rpc := cfg.simulator("ethereum/rpc")
+	if rpc.Parallelism != nil {
+		t.Errorf("per-simulator parallelism not overridden by flag")
+	}
+	if _, ok := rpc.Params["a"]; ok {
+		t.Errorf("per-simulator param not overridden by flag")
+	}
+}
@@ -188,7 +188,7 @@ func reportTAP(t *hivesim.T, clientName string, output io.Reader) error {
 			return err
 		}
 		name := fmt.Sprintf("%s (%s)", test.Description, clientName)
-		testID, err := t.Sim.StartTest(t.SuiteID, name, "")
+		testID, err := t.Sim.StartSubtest(t.SuiteID, t.TestID, name, "")
 		if err != nil {
 			return fmt.Errorf("can't report sub-test result: %v", err)
 		}